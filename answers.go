@@ -0,0 +1,192 @@
+package fds
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// sequenceChoices are the choices an answer can be given in a sequence, as the others take a replacement or a pattern
+var sequenceChoices = []rune{ConfirmYes, ConfirmNo, ConfirmAll, ConfirmAllInFile, ConfirmSkipFile, ConfirmUndo, ConfirmQuit}
+
+/**
+ * AnswerRecord is an answer along with the prompt it answered, recorded as a line of JSON. The match is its Index
+ * in the file, while line operations are told apart by their line only
+ */
+type AnswerRecord struct {
+	File        string `json:"file"`
+	LineNumber  int    `json:"line,omitempty"`
+	Index       int    `json:"match,omitempty"`
+	Choice      string `json:"answer"`
+	Replacement string `json:"replacement,omitempty"`
+	Pattern     string `json:"pattern,omitempty"`
+}
+
+type answerKey struct {
+	file       string
+	lineNumber int
+	index      int
+}
+
+/**
+ * Answers answers prompts with answers given beforehand, either recorded along with the prompts they answer or in a
+ * sequence answering the prompts in the order they are asked. A prompt asked more than once, as when undoing, takes
+ * the next answer recorded for it
+ */
+type Answers struct {
+	mutex    sync.Mutex
+	keyed    map[answerKey][]Answer
+	sequence []Answer
+	err      error
+}
+
+// ReadAnswers reads the answers recorded by RecordAnswers, one AnswerRecord per line
+func ReadAnswers(reader io.Reader) (*Answers, error) {
+	answers := &Answers{keyed: map[answerKey][]Answer{}}
+	scanner := bufio.NewScanner(reader)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var record AnswerRecord
+
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		choice, size := utf8.DecodeRuneInString(record.Choice)
+
+		if size == 0 || size != len(record.Choice) {
+			return nil, fmt.Errorf("line %d: invalid answer %q", lineNumber, record.Choice)
+		}
+
+		key := answerKey{record.File, record.LineNumber, record.Index}
+		answers.keyed[key] = append(answers.keyed[key], Answer{Choice: choice, Replacement: record.Replacement, Pattern: record.Pattern})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return answers, nil
+}
+
+// ParseAnswers reads a sequence of choices, such as "yyn a", spaces being left out
+func ParseAnswers(sequence string) (*Answers, error) {
+	answers := &Answers{}
+
+	for _, choice := range sequence {
+		if choice == ' ' {
+			continue
+		}
+
+		if !slices.Contains(sequenceChoices, choice) {
+			return nil, fmt.Errorf("invalid answer %q, answers in a sequence are one of %q", choice, string(sequenceChoices))
+		}
+
+		answers.sequence = append(answers.sequence, Answer{Choice: choice})
+	}
+
+	return answers, nil
+}
+
+/**
+ * Confirm is a ConfirmFunc giving the next answer for `prompt`. Once there is none left, it fails with an error, also
+ * kept in Err, as a failing ConfirmFunc only quits asking
+ */
+func (a *Answers) Confirm(prompt Prompt) (Answer, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.keyed == nil {
+		if len(a.sequence) == 0 {
+			return a.fail(prompt)
+		}
+
+		answer := a.sequence[0]
+		a.sequence = a.sequence[1:]
+
+		return answer, nil
+	}
+
+	key := answerKey{prompt.File, prompt.LineNumber, prompt.Index}
+	recorded := a.keyed[key]
+
+	if len(recorded) == 0 {
+		return a.fail(prompt)
+	}
+
+	a.keyed[key] = recorded[1:]
+
+	return recorded[0], nil
+}
+
+func (a *Answers) fail(prompt Prompt) (Answer, error) {
+	prompted := "the prompt"
+
+	switch {
+	case prompt.Index > 0:
+		prompted = fmt.Sprintf("match %d, on line %d,", prompt.Index, prompt.LineNumber)
+	case prompt.LineNumber > 0:
+		prompted = fmt.Sprintf("line %d", prompt.LineNumber)
+	}
+
+	err := NewMissingAnswerError(prompt.File, prompted)
+
+	if a.err == nil {
+		a.err = err
+	}
+
+	return Answer{}, err
+}
+
+// Err is the error of the first prompt there was no answer for
+func (a *Answers) Err() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.err
+}
+
+/**
+ * RecordAnswers returns a ConfirmFunc answering as `confirm` does, writing each answer into `writer` as an
+ * AnswerRecord as soon as it is given, so that they can be read by ReadAnswers and replayed
+ */
+func RecordAnswers(confirm ConfirmFunc, writer io.Writer) ConfirmFunc {
+	var mutex sync.Mutex
+
+	encoder := json.NewEncoder(writer)
+
+	return func(prompt Prompt) (Answer, error) {
+		answer, err := confirm(prompt)
+
+		if err != nil {
+			return answer, err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		record := AnswerRecord{
+			File:        prompt.File,
+			LineNumber:  prompt.LineNumber,
+			Index:       prompt.Index,
+			Choice:      string(answer.Choice),
+			Replacement: answer.Replacement,
+			Pattern:     answer.Pattern,
+		}
+
+		if err = encoder.Encode(record); err != nil {
+			return Answer{}, fmt.Errorf("Failed to record answer: %w", err)
+		}
+
+		return answer, nil
+	}
+}
@@ -0,0 +1,176 @@
+package fds
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseAnswers(t *testing.T) {
+	tests := []struct {
+		sequence string
+		want     string
+		wantErr  bool
+	}{
+		{sequence: "yyn a", want: "yyna"},
+		{sequence: "AdquN", wantErr: true},
+		{sequence: "e", wantErr: true},
+		{sequence: "", want: ""},
+	}
+
+	for _, tc := range tests {
+		answers, err := ParseAnswers(tc.sequence)
+
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseAnswers(%q) returned error %v, want error %v", tc.sequence, err, tc.wantErr)
+
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		var choices []rune
+
+		for _, answer := range answers.sequence {
+			choices = append(choices, answer.Choice)
+		}
+
+		if string(choices) != tc.want {
+			t.Errorf("ParseAnswers(%q) = %q, want %q", tc.sequence, string(choices), tc.want)
+		}
+	}
+}
+
+func TestReadAnswers(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "Records", input: `{"file":"a","line":1,"match":1,"answer":"y"}` + "\n\n" + `{"file":"a","line":2,"match":2,"answer":"e","replacement":"baz"}`},
+		{name: "Invalid JSON", input: `{"file":"a",`, wantErr: true},
+		{name: "Answer of more than one rune", input: `{"file":"a","answer":"yn"}`, wantErr: true},
+		{name: "Empty answer", input: `{"file":"a","answer":""}`, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ReadAnswers(strings.NewReader(tc.input))
+
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ReadAnswers() returned error %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnswers_Confirm(t *testing.T) {
+	input := `{"file":"a","line":1,"match":1,"answer":"y"}
+{"file":"a","line":1,"match":2,"answer":"u"}
+{"file":"a","line":1,"match":1,"answer":"n"}
+{"file":"b","line":3,"answer":"y"}`
+
+	answers, err := ReadAnswers(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatalf("ReadAnswers() returned an unexpected error '%s'", err)
+	}
+
+	prompts := []struct {
+		prompt  Prompt
+		want    rune
+		wantErr bool
+	}{
+		{prompt: Prompt{File: "a", LineNumber: 1, Index: 1}, want: ConfirmYes},
+		{prompt: Prompt{File: "a", LineNumber: 1, Index: 2}, want: ConfirmUndo},
+		{prompt: Prompt{File: "a", LineNumber: 1, Index: 1}, want: ConfirmNo},
+		{prompt: Prompt{File: "a", LineNumber: 1, Index: 2}, wantErr: true},
+		{prompt: Prompt{File: "b", LineNumber: 3}, want: ConfirmYes},
+	}
+
+	for _, tc := range prompts {
+		answer, err := answers.Confirm(tc.prompt)
+
+		if (err != nil) != tc.wantErr || answer.Choice != tc.want {
+			t.Errorf("Answers.Confirm(%+v) = %c, %v, want %c with error %v", tc.prompt, answer.Choice, err, tc.want, tc.wantErr)
+		}
+	}
+
+	if !errors.Is(answers.Err(), ErrMissingAnswer) {
+		t.Errorf("Answers.Err() = %v, want %v", answers.Err(), ErrMissingAnswer)
+	}
+}
+
+func TestAnswers_ConfirmSequence(t *testing.T) {
+	answers, _ := ParseAnswers("ny")
+
+	for _, want := range []rune{ConfirmNo, ConfirmYes} {
+		if answer, err := answers.Confirm(Prompt{File: "a"}); err != nil || answer.Choice != want {
+			t.Errorf("Answers.Confirm() = %c, %v, want %c", answer.Choice, err, want)
+		}
+	}
+
+	if _, err := answers.Confirm(Prompt{File: "a"}); !errors.Is(err, ErrMissingAnswer) {
+		t.Errorf("Answers.Confirm() returned error %v once out of answers, want %v", err, ErrMissingAnswer)
+	}
+}
+
+func TestRecordAnswers_Replayed(t *testing.T) {
+	files := map[string]string{"/src/a": "foo foo\nfoo", "/src/b": "foo"}
+
+	run := func(confirm ConfirmFunc) map[string]string {
+		fileSystem := newTestFileSystem(files, t)
+
+		config := NewConfig()
+		config.FileSystem = fileSystem
+		config.Confirm = true
+
+		options := Options{Search: "foo", Replace: "bar", Paths: []string{"/src"}, Config: config, Confirm: confirm}
+
+		if _, err := Run(context.Background(), options); err != nil {
+			t.Fatalf("Run() returned an unexpected error '%s'", err)
+		}
+
+		result := map[string]string{}
+
+		for path := range files {
+			content, _ := fileSystem.ReadFile(path)
+			result[path] = string(content)
+		}
+
+		return result
+	}
+
+	given := []Answer{{Choice: ConfirmYes}, {Choice: ConfirmUndo}, {Choice: ConfirmEdit, Replacement: "baz"}, {Choice: ConfirmNo}, {Choice: ConfirmYes}, {Choice: ConfirmYes}}
+
+	var record bytes.Buffer
+
+	recorded := run(RecordAnswers(func(Prompt) (Answer, error) {
+		answer := given[0]
+		given = given[1:]
+
+		return answer, nil
+	}, &record))
+
+	if want := "baz foo\nbar"; recorded["/src/a"] != want {
+		t.Errorf("Run() while recording = %q, want %q", recorded["/src/a"], want)
+	}
+
+	answers, err := ReadAnswers(&record)
+
+	if err != nil {
+		t.Fatalf("ReadAnswers() returned an unexpected error '%s'", err)
+	}
+
+	if replayed := run(answers.Confirm); replayed["/src/a"] != recorded["/src/a"] || replayed["/src/b"] != recorded["/src/b"] {
+		t.Errorf("Run() replaying answers = %q, want %q", replayed, recorded)
+	}
+
+	if err := answers.Err(); err != nil {
+		t.Errorf("Answers.Err() = %v, want nil", err)
+	}
+}
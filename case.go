@@ -0,0 +1,141 @@
+package fds
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type CaseShape int
+
+const (
+	CaseUnknown CaseShape = iota
+	CaseLower
+	CaseUpper
+	CaseTitle
+	CaseCamel
+	CasePascal
+)
+
+/**
+ * DetectCaseShape tells how the letters of `subject` are cased: lower (user), UPPER (USER), Title (User),
+ * camel (userId) or Pascal (UserId). Subjects without letters, or with a mix that fits none of those, are unknown
+ */
+func DetectCaseShape(subject string) CaseShape {
+	var letters, uppers int
+	var firstUpper, restUpper bool
+
+	for _, char := range subject {
+		if !unicode.IsLetter(char) {
+			continue
+		}
+
+		isUpper := unicode.IsUpper(char)
+
+		if letters == 0 {
+			firstUpper = isUpper
+		} else if isUpper {
+			restUpper = true
+		}
+
+		if isUpper {
+			uppers++
+		}
+
+		letters++
+	}
+
+	switch {
+	case letters == 0:
+		return CaseUnknown
+	case uppers == 0:
+		return CaseLower
+	case uppers == letters && letters > 1:
+		return CaseUpper
+	case firstUpper && !restUpper:
+		return CaseTitle
+	case !firstUpper && restUpper:
+		return CaseCamel
+	case firstUpper && restUpper:
+		return CasePascal
+	}
+
+	return CaseUnknown
+}
+
+/**
+ * PreserveCase adapts `replace` to the case shape of `match`, so that replacing "user" by "account"
+ * turns "User" into "Account", "USER" into "ACCOUNT" and "userId" into "accountId"
+ */
+func PreserveCase(match, replace string) string {
+	switch DetectCaseShape(match) {
+	case CaseLower:
+		return strings.ToLower(replace)
+	case CaseUpper:
+		return strings.ToUpper(replace)
+	case CaseTitle:
+		return upperFirst(replace)
+	case CaseCamel:
+		return joinWords(splitWords(replace), false)
+	case CasePascal:
+		return joinWords(splitWords(replace), true)
+	}
+
+	return replace
+}
+
+func upperFirst(subject string) string {
+	first, size := utf8.DecodeRuneInString(subject)
+
+	if first == utf8.RuneError {
+		return subject
+	}
+
+	return string(unicode.ToUpper(first)) + subject[size:]
+}
+
+// splitWords breaks "account_id", "account-id", "account id" and "accountId" into ["account", "id"]
+func splitWords(subject string) []string {
+	var words []string
+	var word []rune
+
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+
+	for _, char := range subject {
+		if char == '_' || char == '-' || unicode.IsSpace(char) {
+			flush()
+			continue
+		}
+
+		if unicode.IsUpper(char) && len(word) > 0 && !unicode.IsUpper(word[len(word)-1]) {
+			flush()
+		}
+
+		word = append(word, char)
+	}
+
+	flush()
+
+	return words
+}
+
+func joinWords(words []string, pascal bool) string {
+	var builder strings.Builder
+
+	for i, word := range words {
+		word = strings.ToLower(word)
+
+		if i > 0 || pascal {
+			word = upperFirst(word)
+		}
+
+		builder.WriteString(word)
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,51 @@
+package fds
+
+import "testing"
+
+func TestDetectCaseShape(t *testing.T) {
+	var tests = []struct {
+		subject string
+		want    CaseShape
+	}{
+		{subject: "user", want: CaseLower},
+		{subject: "USER", want: CaseUpper},
+		{subject: "User", want: CaseTitle},
+		{subject: "U", want: CaseTitle},
+		{subject: "userId", want: CaseCamel},
+		{subject: "UserId", want: CasePascal},
+		{subject: "USER_ID", want: CaseUpper},
+		{subject: "123", want: CaseUnknown},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.subject, func(t *testing.T) {
+			if result := DetectCaseShape(tc.subject); result != tc.want {
+				t.Errorf("DetectCaseShape(%q) = %d, want %d", tc.subject, result, tc.want)
+			}
+		})
+	}
+}
+
+func TestPreserveCase(t *testing.T) {
+	var tests = []struct {
+		match   string
+		replace string
+		want    string
+	}{
+		{match: "user", replace: "account", want: "account"},
+		{match: "USER", replace: "account", want: "ACCOUNT"},
+		{match: "User", replace: "account", want: "Account"},
+		{match: "userId", replace: "account_id", want: "accountId"},
+		{match: "UserId", replace: "account-id", want: "AccountId"},
+		{match: "USER_ID", replace: "account_id", want: "ACCOUNT_ID"},
+		{match: "123", replace: "account", want: "account"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.match, func(t *testing.T) {
+			if result := PreserveCase(tc.match, tc.replace); result != tc.want {
+				t.Errorf("PreserveCase(%q, %q) = %q, want %q", tc.match, tc.replace, result, tc.want)
+			}
+		})
+	}
+}
@@ -1,94 +1,421 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 
 	"github.com/gabrieloliverio/fds"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/pflag"
+	"golang.org/x/term"
 )
 
 var (
-	literal, insensitive, confirm, verbose, help bool
-	workers int
-	ignoreGlobs                            fds.IgnoreGlobs
-	err                                    error
-	defaultAnswer                          = fds.ConfirmAnswer('n')
-	confirmAnswer                          = &defaultAnswer
+	literal, insensitive, preserveCase, confirm, verbose, help, noProgress, tui bool
+	workers, maxPerLine, maxPerFile, maxTotal, contextLines                     int
+	ignoreGlobs                                                                 fds.IgnoreGlobs
+	selection                                                                   fds.Selection
+	operations                                                                  fds.LineOperations
+	lineEnding                                                                  fds.LineEnding
+	encoding                                                                    fds.Encoding
+	answersFrom, recordAnswersTo                                                string
+	colorMode                                                                   fds.ColorMode
+	outputFormat                                                                fds.OutputFormat
+
+	// defaults are read from the configuration files, which flags not supplied fall back to
+	defaults fds.Defaults
+
+	// defaultsErr is the error reading the configuration files, only reported once help is out of the way
+	defaultsErr error
+
+	// progressOutput is where progress is displayed, when it is a terminal
+	progressOutput io.Writer
+
+	// terminalPath is the terminal prompts are asked on when the content replaced in is read from stdin
+	terminalPath = "/dev/tty"
 )
 
 func main() {
+	workingDir, _ := os.Getwd()
+	defaults, defaultsErr = fds.LoadDefaults(workingDir)
+	colorMode, lineEnding, encoding, outputFormat = defaults.Color, defaults.EOL, defaults.Encoding, defaults.OutputFormat
+
 	pflag.Usage = func() { fmt.Fprint(os.Stderr, fds.Usage) }
-	pflag.BoolVarP(&literal, "literal", "l", false, fds.LiteralUsage)
-	pflag.BoolVarP(&insensitive, "insensitive", "i", false, fds.InsensitiveUsage)
-	pflag.BoolVarP(&confirm, "confirm", "c", false, fds.ConfirmUsage)
-	pflag.BoolVarP(&verbose, "verbose", "v", false, fds.VerboseUsage)
+	pflag.BoolVarP(&literal, "literal", "l", defaults.Literal, fds.LiteralUsage)
+	pflag.BoolVarP(&insensitive, "insensitive", "i", defaults.Insensitive, fds.InsensitiveUsage)
+	pflag.BoolVar(&preserveCase, "preserve-case", defaults.PreserveCase, fds.PreserveCaseUsage)
+	pflag.BoolVarP(&confirm, "confirm", "c", defaults.Confirm, fds.ConfirmUsage)
+	pflag.BoolVar(&tui, "tui", false, fds.TUIUsage)
+	pflag.StringVar(&answersFrom, "answers", "", fds.AnswersUsage)
+	pflag.StringVar(&recordAnswersTo, "record-answers", "", fds.RecordUsage)
+	pflag.IntVarP(&contextLines, "context", "C", defaults.Context, fds.ContextUsage)
+	pflag.BoolVarP(&verbose, "verbose", "v", defaults.Verbose, fds.VerboseUsage)
 	pflag.BoolVarP(&help, "help", "h", false, fds.HelpUsage)
-	pflag.IntVar(&workers, "workers", 4, fds.WorkersUsage)
+	pflag.IntVar(&workers, "workers", defaults.Workers, fds.WorkersUsage)
+	pflag.IntVar(&maxPerLine, "max-per-line", defaults.MaxPerLine, fds.MaxPerLineUsage)
+	pflag.IntVar(&maxPerFile, "max-per-file", defaults.MaxPerFile, fds.MaxPerFileUsage)
+	pflag.IntVar(&maxTotal, "max-total", defaults.MaxTotal, fds.MaxTotalUsage)
+	pflag.Var(&selection.Lines, "lines", fds.LinesUsage)
+	pflag.Var(&selection.After, "after-pattern", fds.AfterUsage)
+	pflag.Var(&selection.Before, "before-pattern", fds.BeforeUsage)
+	pflag.Var(&selection.Where, "where", fds.WhereUsage)
+	pflag.BoolVar(&operations.Delete, "delete-line", false, fds.DeleteLineUsage)
+	pflag.StringVar(&operations.InsertBefore, "insert-before", "", fds.InsertBeforeUsage)
+	pflag.StringVar(&operations.InsertAfter, "insert-after", "", fds.InsertAfterUsage)
+	pflag.Var(&lineEnding, "eol", fds.EOLUsage)
+	pflag.Var(&encoding, "encoding", fds.EncodingUsage)
 	pflag.Var(&ignoreGlobs, "ignore-globs", fds.IgnoreUsage)
+	pflag.BoolVar(&noProgress, "no-progress", defaults.NoProgress, fds.NoProgressUsage)
+	pflag.Var(&colorMode, "color", fds.ColorUsage)
+	pflag.Var(&outputFormat, "output-format", fds.OutputFormatUsage)
 
 	pflag.Parse()
 
+	// globs supplied replace the ones of configuration files, as any other flag does
+	if !pflag.CommandLine.Changed("ignore-globs") {
+		ignoreGlobs = defaults.IgnoreGlobs
+	}
+
+	colorMode.Apply()
+
 	config := fds.NewConfig()
-	config.Flags = map[string]bool{"confirm": confirm, "insensitive": insensitive, "literal": literal, "verbose": verbose}
+	config.Literal = literal
+	config.Insensitive = insensitive
+	config.PreserveCase = preserveCase
+	config.Confirm = confirm || answersFrom != "" || recordAnswersTo != ""
+	config.Verbose = verbose
 	config.Workers = workers
+	config.Context = contextLines
+	config.Limits = fds.Limits{PerLine: maxPerLine, PerFile: maxPerFile, Total: maxTotal}
+	config.Selection = selection
+	config.Operations = operations
+	config.LineEnding = lineEnding
+	config.Encoding = encoding
+	config.OutputFormat = outputFormat
 
-	if err := execute(os.Args[1:], config, os.Stdin, os.Stdout); err != nil {
-		if thrownErr, ok := err.(fds.Error); ok {
-			fmt.Fprintln(os.Stderr, thrownErr.Error())
-			os.Exit(thrownErr.Code)
-		}
+	if !noProgress && isatty.IsTerminal(os.Stderr.Fd()) {
+		progressOutput = os.Stderr
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	restoreTerminal := saveTerminal()
+
+	go func() {
+		<-ctx.Done()
+		// prompts cannot be interrupted, so a second signal quits right away, which would leave raw mode on
+		stop()
+		restoreTerminal()
+		fmt.Fprintln(os.Stderr, "Interrupted, leaving the files in progress untouched. Interrupt again to quit right away")
+	}()
 
-		fmt.Println(err)
-		os.Exit(1)
+	status, err := execute(ctx, pflag.Args(), config, os.Stdin, os.Stdout)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(fds.ExitCode(err))
 	}
+
+	os.Exit(status)
 }
 
-func execute(inputArgs []string, config fds.Config, stdin *os.File, stdout io.Writer) (err error) {
-	if config.Flags["help"] {
-		fmt.Fprint(stdout, fds.Usage)
+/**
+ * saveTerminal returns a func restoring the terminal, if there is one, to the state it is in now. It is the one
+ * prompts are asked on, whether through stdin or not
+ */
+func saveTerminal() func() {
+	terminal, err := os.OpenFile(terminalPath, os.O_RDWR, 0)
 
-		return
+	if err != nil {
+		return func() {}
 	}
 
-	args, err := fds.ReadArgs(stdin, inputArgs)
+	fd := int(terminal.Fd())
+	state, err := term.GetState(fd)
 
 	if err != nil {
-		return
+		terminal.Close()
+
+		return func() {}
+	}
+
+	return func() {
+		term.Restore(fd, state)
+		terminal.Close()
+	}
+}
+
+/**
+ * execute runs fds, returning fds.ExitNoMatch as status when nothing was replaced. When interrupted through `ctx`,
+ * the error lists the files replaced in so far
+ */
+func execute(ctx context.Context, inputArgs []string, config fds.Config, stdin *os.File, stdout io.Writer) (status int, err error) {
+	if help {
+		fmt.Fprint(stdout, fds.Usage)
+
+		return fds.ExitSuccess, nil
+	}
+
+	if defaultsErr != nil {
+		return 0, defaultsErr
+	}
+
+	// `config show` is a subcommand whatever stdin holds, rather than a search pattern and its replacement
+	if slices.Equal(inputArgs, []string{"config", "show"}) {
+		return fds.ExitSuccess, showConfig(config, stdout)
 	}
 
-	err = fds.Validate(args, config.Flags)
+	var args fds.Args
+
+	if config.Operations.IsSet() {
+		args, err = fds.ReadLineOperationArgs(inputArgs)
+	} else {
+		args, err = fds.ReadArgs(stdin, inputArgs)
+	}
 
 	if err != nil {
 		return
 	}
 
+	options := fds.Options{
+		Search:      args.Search,
+		Replace:     args.Replace,
+		Output:      stdout,
+		IgnoreGlobs: ignoreGlobs,
+		Config:      config,
+		Confirm:     fds.NewTerminalConfirm(stdin, stdout),
+	}
+
 	if args.Path.Value == "" {
-		replacer := fds.NewLineReplacer(args.Search, args.Replace, config.Flags)
-		result, _ := replacer.Replace(args.Subject)
+		options.Input = strings.NewReader(args.Subject)
+	} else {
+		options.Paths = []string{args.Path.Value}
+	}
 
-		fmt.Fprint(stdout, result)
+	switch {
+	case answersFrom != "":
+		answers, readErr := readAnswers(answersFrom)
 
-		return
+		if readErr != nil {
+			return 0, readErr
+		}
+
+		options.Confirm = answers.Confirm
+
+		// running out of answers quits asking, which is only an error for the answers
+		defer func() {
+			if err == nil {
+				err = answers.Err()
+			}
+		}()
+	case options.Input != nil && config.Confirm:
+		// stdin holds the content, so prompts are asked on the terminal instead, the result still being written to stdout
+		terminal, openErr := os.OpenFile(terminalPath, os.O_RDWR, 0)
+
+		if openErr != nil {
+			return 0, fds.NewConfirmNotOnFileError()
+		}
+
+		defer terminal.Close()
+
+		options.Confirm = fds.NewTerminalConfirm(terminal, terminal)
+	}
+
+	if recordAnswersTo != "" {
+		record, createErr := os.Create(recordAnswersTo)
+
+		if createErr != nil {
+			return 0, fds.NewFileWriteError(recordAnswersTo, createErr)
+		}
+
+		defer record.Close()
+
+		options.Confirm = fds.RecordAnswers(options.Confirm, record)
 	}
 
-	if args.Path.IsFile() {
-		replacer := fds.NewFileReplacer(args.Path.Value, args.Search, args.Replace, config)
+	if tui {
+		return review(ctx, options, stdin, stdout)
+	}
 
-		err = fds.ReplaceInFile(replacer, stdin, stdout, confirmAnswer)
+	// prompts and debug information would be drawn over by the progress line
+	if progressOutput != nil && options.Paths != nil && !config.Confirm && !config.Verbose {
+		progress := fds.NewTerminalProgress(progressOutput)
+		options.Progress = progress.Update
 
-		return
+		defer progress.Finish()
+	}
+
+	report, err := fds.Run(ctx, options)
+
+	if errors.Is(err, fds.ErrInterrupted) {
+		err = interruptedError(report, err)
+	}
+
+	if writeErr := writeReport(options, report, err, stdout); writeErr != nil {
+		return 0, writeErr
+	}
+
+	return exitStatus(report.Replaced()), err
+}
+
+/**
+ * review finds the matches of `options` and shows them on a full-screen ReviewScreen, with the terminal of `stdin` in
+ * raw mode, before replacing the ones selected
+ */
+func review(ctx context.Context, options fds.Options, stdin *os.File, stdout io.Writer) (int, error) {
+	fd := int(stdin.Fd())
+
+	// stdin is checked by fds.Validate, but a review is only possible on a terminal
+	if !term.IsTerminal(fd) {
+		return 0, fds.NewReviewNotOnFileError()
 	}
 
-	files, err := fds.GetFilesInDir(args.Path.Value, ignoreGlobs, verbose)
+	found, err := fds.NewReview(ctx, options)
 
 	if err != nil {
-		return
+		return 0, err
+	}
+
+	if matches, _ := found.Count(); matches == 0 {
+		return fds.ExitNoMatch, nil
+	}
+
+	state, err := term.MakeRaw(fd)
+
+	if err != nil {
+		return 0, err
+	}
+
+	size := func() (int, int) {
+		width, height, err := term.GetSize(fd)
+
+		if err != nil {
+			return 80, 24
+		}
+
+		return width, height
+	}
+
+	apply, err := fds.NewReviewScreen(found, stdin, stdout, size).Run(ctx)
+	term.Restore(fd, state)
+
+	if err != nil || !apply {
+		return fds.ExitNoMatch, err
+	}
+
+	report, err := found.Apply(ctx, options)
+
+	if errors.Is(err, fds.ErrInterrupted) {
+		err = interruptedError(report, err)
+	}
+
+	if writeErr := writeReport(options, report, err, stdout); writeErr != nil {
+		return 0, writeErr
+	}
+
+	return exitStatus(report.Replaced()), err
+}
+
+// writeReport writes the report of replacing in files into `stdout`, unless `err` came before any file was replaced in
+func writeReport(options fds.Options, report fds.Report, err error, stdout io.Writer) error {
+	if options.Paths == nil || (err != nil && len(report.Files) == 0) {
+		return nil
+	}
+
+	return fds.WriteReport(stdout, report, options.Config.OutputFormat)
+}
+
+/**
+ * showConfig prints the configuration in effect, each setting along with the flag or configuration file setting it.
+ * Only the settings configuration files may hold are printed, leaving out the ones of a single run, such as --lines
+ */
+func showConfig(config fds.Config, stdout io.Writer) error {
+	settings := []fds.Setting{
+		{Name: "workers", Value: config.Workers},
+		{Name: "context", Value: config.Context},
+		{Name: "ignore-globs", Value: []string(ignoreGlobs)},
+		{Name: "color", Value: colorMode},
+		{Name: "literal", Value: config.Literal},
+		{Name: "insensitive", Value: config.Insensitive},
+		{Name: "preserve-case", Value: config.PreserveCase},
+		{Name: "confirm", Value: config.Confirm},
+		{Name: "verbose", Value: config.Verbose},
+		{Name: "max-per-line", Value: config.Limits.PerLine},
+		{Name: "max-per-file", Value: config.Limits.PerFile},
+		{Name: "max-total", Value: config.Limits.Total},
+		{Name: "eol", Value: config.LineEnding},
+		{Name: "encoding", Value: config.Encoding},
+		{Name: "no-progress", Value: noProgress},
+		{Name: "output-format", Value: config.OutputFormat},
 	}
 
-	err = fds.ReplaceInFiles(files, stdin, stdout, args, config, confirmAnswer)
+	for i, setting := range settings {
+		settings[i].Source = defaults.Source(setting.Name)
 
-	return
+		if pflag.CommandLine.Changed(setting.Name) {
+			settings[i].Source = "flag"
+		}
+	}
+
+	return fds.PrintSettings(stdout, settings)
+}
+
+// readAnswers reads the answers recorded in the file `answers`, or the sequence it is when there is no such file
+func readAnswers(answers string) (*fds.Answers, error) {
+	file, err := os.Open(answers)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		parsed, parseErr := fds.ParseAnswers(answers)
+
+		if parseErr != nil {
+			return nil, fds.NewAnswersReadError(answers, parseErr)
+		}
+
+		return parsed, nil
+	}
+
+	if err != nil {
+		return nil, fds.NewAnswersReadError(answers, err)
+	}
+
+	defer file.Close()
+
+	recorded, err := fds.ReadAnswers(file)
+
+	if err != nil {
+		return nil, fds.NewAnswersReadError(answers, err)
+	}
+
+	return recorded, nil
 }
 
+func interruptedError(report fds.Report, err error) error {
+	var completed []string
+
+	for _, file := range report.Files {
+		if file.Replaced {
+			completed = append(completed, file.Path)
+		}
+	}
+
+	if len(completed) == 0 {
+		return fmt.Errorf("%w. No file was replaced in", err)
+	}
+
+	return fmt.Errorf("%w. Replaced in %d files:\n%s", err, len(completed), strings.Join(completed, "\n"))
+}
+
+func exitStatus(replaced bool) int {
+	if !replaced {
+		return fds.ExitNoMatch
+	}
+
+	return fds.ExitSuccess
+}
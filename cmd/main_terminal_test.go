@@ -0,0 +1,77 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gabrieloliverio/fds"
+	"golang.org/x/sys/unix"
+)
+
+func TestExecuteConfirmStdinOnTerminal(t *testing.T) {
+	keyboard, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+
+	if err != nil {
+		t.Skipf("Pseudo-terminals are not available: %s", err)
+	}
+
+	defer keyboard.Close()
+
+	unix.IoctlSetPointerInt(int(keyboard.Fd()), unix.TIOCSPTLCK, 0)
+	number, err := unix.IoctlGetInt(int(keyboard.Fd()), unix.TIOCGPTN)
+
+	if err != nil {
+		t.Fatalf("Failed to get pseudo-terminal number: %s", err)
+	}
+
+	terminalPath = fmt.Sprintf("/dev/pts/%d", number)
+	defer func() { terminalPath = "/dev/tty" }()
+
+	// each answer is typed once its prompt is printed on the terminal
+	go func() {
+		var printed string
+
+		buffer := make([]byte, 1024)
+
+		for _, key := range []string{"n", "y"} {
+			for !strings.HasSuffix(printed, "?]: ") {
+				n, err := keyboard.Read(buffer)
+
+				if err != nil {
+					return
+				}
+
+				printed += string(buffer[:n])
+			}
+
+			printed = ""
+			keyboard.WriteString(key)
+		}
+
+		io.Copy(io.Discard, keyboard)
+	}()
+
+	config := fds.NewConfig()
+	config.Confirm = true
+
+	stdin := newStdin("lorem lorem", t)
+
+	var stdout bytes.Buffer
+
+	status, err := execute(context.Background(), []string{"lorem", "foo"}, config, stdin, &stdout)
+
+	if err != nil || status != fds.ExitSuccess {
+		t.Fatalf("execute() = %d, %v, want %d", status, err, fds.ExitSuccess)
+	}
+
+	if want := "lorem foo"; stdout.String() != want {
+		t.Errorf("execute() wrote %q, want %q", stdout.String(), want)
+	}
+}
@@ -2,25 +2,63 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/gabrieloliverio/fds"
 )
 
+/**
+ * newStdin returns a file holding `content`, to be read as stdin. It is a file on disk rather than a MemoryFileSystem
+ * one, as execute reads stdin as an *os.File, statting it to tell content piped in from paths supplied
+ */
+func newStdin(content string, t *testing.T) *os.File {
+	stdin, err := os.Create(filepath.Join(t.TempDir(), "stdin"))
+
+	if err != nil {
+		t.Fatalf("Failed to create stdin: %s", err)
+	}
+
+	t.Cleanup(func() { stdin.Close() })
+
+	stdin.WriteString(content)
+	stdin.Seek(0, io.SeekStart)
+
+	return stdin
+}
+
+/**
+ * writeTestFiles writes `files`, by name, into a temporary directory it returns. They are written on disk, as execute
+ * looks the paths supplied up on the OS file system rather than on Config.FileSystem
+ */
+func writeTestFiles(files map[string]string, t *testing.T) string {
+	dir := t.TempDir()
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file %s: %s", name, err)
+		}
+	}
+
+	return dir
+}
+
 func TestExecuteHelp(t *testing.T) {
-	tempDir := t.TempDir()
+	help = true
+	defer func() { help = false }()
 
 	config := fds.NewConfig()
-	config.Flags = map[string]bool{"help": true}
 
 	var stdout bytes.Buffer
-	var stdin, _ = os.Create(filepath.Join(tempDir, "stdin"))
-	stdin.WriteString("lorem ipsum")
+	stdin := newStdin("lorem ipsum", t)
 
-	err := execute([]string{"cmd"}, config, stdin, &stdout)
+	_, err := execute(context.Background(), []string{"cmd"}, config, stdin, &stdout)
 
 	if err != nil {
 		t.Errorf("execute() was not supposed to return error, but err %s was returned", err)
@@ -34,118 +72,114 @@ func TestExecuteHelp(t *testing.T) {
 	}
 }
 
-func TestExecuteInvalidArgumentError(t *testing.T) {
-	tempDir := t.TempDir()
+func TestExecuteConfigFileError(t *testing.T) {
+	defaultsErr = fds.NewConfigFileError(".fds.toml", errors.New("unknown setting \"worker\""))
+	defer func() { defaultsErr = nil }()
+
+	stdin, _ := os.Open(os.DevNull)
+	defer stdin.Close()
+
+	var stdout bytes.Buffer
+
+	// help is printed whatever the configuration files hold
+	help = true
+	_, err := execute(context.Background(), nil, fds.NewConfig(), stdin, &stdout)
+	help = false
 
+	if err != nil || stdout.String() != fds.Usage {
+		t.Errorf("execute() = %v, printing %q, want the usage printed", err, stdout.String())
+	}
+
+	if _, err = execute(context.Background(), []string{"config", "show"}, fds.NewConfig(), stdin, &stdout); !errors.Is(err, fds.ErrConfigFile) {
+		t.Errorf("execute() returned error %v, want ErrConfigFile", err)
+	}
+}
+
+func TestExecuteInvalidArgumentError(t *testing.T) {
 	config := fds.NewConfig()
-	config.Flags = map[string]bool{}
 
 	var stdout bytes.Buffer
-	var stdin, _ = os.Create(filepath.Join(tempDir, "stdin"))
-	stdin.WriteString("lorem ipsum")
+	stdin := newStdin("lorem ipsum", t)
 
-	err := execute([]string{"cmd"}, config, stdin, &stdout)
+	_, err := execute(context.Background(), []string{"cmd"}, config, stdin, &stdout)
 
 	if err == nil {
 		t.Error("execute() was supposed to return error, but none was returned")
 	}
 
-	if caughtErr, ok := err.(fds.Error); ok {
-		if caughtErr.Code != 43 {
-			t.Errorf("execute() was supposed to return an Invalid Arguments error (code 43). Error %d was returned", caughtErr.Code)
-		}
+	if code := fds.ExitCode(err); code != 43 {
+		t.Errorf("execute() was supposed to return an Invalid Arguments error (code 43). Error %d was returned", code)
 	}
 }
 
 func TestExecuteLiteralAndInsensitiveError(t *testing.T) {
-	tempDir := t.TempDir()
-
 	config := fds.NewConfig()
-	config.Flags = map[string]bool{"literal": true, "insensitive": true}
+	config.Literal = true
+	config.Insensitive = true
 
 	args := []string{"foo", "bar"}
 
 	var stdout bytes.Buffer
-	var stdin, _ = os.Create(filepath.Join(tempDir, "stdin"))
-	stdin.WriteString("lorem ipsum")
+	stdin := newStdin("lorem ipsum", t)
 
-	err := execute(args, config, stdin, &stdout)
+	_, err := execute(context.Background(), args, config, stdin, &stdout)
 
 	if err == nil {
 		t.Error("execute() was supposed to return error, but none was returned")
 	}
 
-	if caughtErr, ok := err.(fds.Error); ok {
-		if caughtErr.Code != 45 {
-			t.Errorf("execute() was supposed to return an Literal Insensitive error (code 45). Error %d was returned", caughtErr.Code)
-		}
+	if code := fds.ExitCode(err); code != 45 {
+		t.Errorf("execute() was supposed to return an Literal Insensitive error (code 45). Error %d was returned", code)
 	}
 }
 
 func TestExecuteFileNotFoundError(t *testing.T) {
-	tempDir := t.TempDir()
-
 	config := fds.NewConfig()
-	config.Flags = map[string]bool{}
 
 	args := []string{"foo", "bar", "baz"}
 
-	var stdin, _ = os.Create(filepath.Join(tempDir, "stdin"))
+	stdin := newStdin("", t)
 	var stdout bytes.Buffer
 
-	err := execute(args, config, stdin, &stdout)
+	_, err := execute(context.Background(), args, config, stdin, &stdout)
 
 	if err == nil {
 		t.Error("execute() was supposed to return error, but none was returned")
 	}
 
-	if caughtErr, ok := err.(fds.Error); ok {
-		if caughtErr.Code != 44 {
-			t.Errorf("execute() was supposed to return an File not Found error (code 44). Error %d was returned", caughtErr.Code)
-		}
+	if code := fds.ExitCode(err); code != 44 {
+		t.Errorf("execute() was supposed to return an File not Found error (code 44). Error %d was returned", code)
 	}
 }
 
 func TestExecuteInvalidRegexError(t *testing.T) {
-	tempDir := t.TempDir()
-
 	config := fds.NewConfig()
-	config.Flags = map[string]bool{}
 
 	args := []string{"(lorem", "bar"}
 
 	var stdout bytes.Buffer
-	var stdin, _ = os.Create(filepath.Join(tempDir, "stdin"))
-	stdin.WriteString("lorem ipsum")
-	stdin.Seek(0, io.SeekStart)
+	stdin := newStdin("lorem ipsum", t)
 
-	err := execute(args, config, stdin, &stdout)
+	_, err := execute(context.Background(), args, config, stdin, &stdout)
 
 	if err == nil {
 		t.Error("execute() was supposed to return error, but none was returned")
 	}
 
-	if caughtErr, ok := err.(fds.Error); ok {
-		if caughtErr.Code != 42 {
-			t.Errorf("execute() was supposed to return an Invalid RegExp error (code 42). Error %d was returned", caughtErr.Code)
-		}
+	if code := fds.ExitCode(err); code != 42 {
+		t.Errorf("execute() was supposed to return an Invalid RegExp error (code 42). Error %d was returned", code)
 	}
 }
 
 func TestExecuteWithStdinSuccess(t *testing.T) {
-	tempDir := t.TempDir()
-
 	config := fds.NewConfig()
-	config.Flags = map[string]bool{}
 
 	args := []string{"lorem", "bar"}
 
 	var stdout bytes.Buffer
-	var stdin, _ = os.Create(filepath.Join(tempDir, "stdin"))
-	stdin.WriteString("lorem ipsum")
-	stdin.Seek(0, io.SeekStart)
+	stdin := newStdin("lorem ipsum", t)
 
-	err := execute(args, config, stdin, &stdout)
+	_, err := execute(context.Background(), args, config, stdin, &stdout)
 
 	if err != nil {
 		t.Errorf("execute() was not supposed to return error, but %q was returned", err)
@@ -160,23 +194,15 @@ func TestExecuteWithStdinSuccess(t *testing.T) {
 }
 
 func TestExecuteWithFileSuccess(t *testing.T) {
-	tempDir := t.TempDir()
-
 	config := fds.NewConfig()
-	config.Flags = map[string]bool{}
-
-	path := filepath.Join(tempDir, "input")
-	file, err := os.Create(path)
-
-	file.WriteString("lorem ipsum")
-	file.Seek(0, io.SeekStart)
 
+	path := filepath.Join(writeTestFiles(map[string]string{"input": "lorem ipsum"}, t), "input")
 	args := []string{"lorem", "bar", path}
 
-	var stdin, _ = os.Create(filepath.Join(tempDir, "stdin"))
+	stdin := newStdin("", t)
 	var stdout bytes.Buffer
 
-	err = execute(args, config, stdin, &stdout)
+	_, err := execute(context.Background(), args, config, stdin, &stdout)
 
 	if err != nil {
 		t.Errorf("execute() was not supposed to return error, but %q was returned", err)
@@ -191,23 +217,16 @@ func TestExecuteWithFileSuccess(t *testing.T) {
 }
 
 func TestExecuteWithFileAndInsensitiveFlagSuccess(t *testing.T) {
-	tempDir := t.TempDir()
-
-	path := filepath.Join(tempDir, "input")
-	file, err := os.Create(path)
-
-	file.WriteString("Lorem ipsum")
-	file.Seek(0, io.SeekStart)
-
+	path := filepath.Join(writeTestFiles(map[string]string{"input": "Lorem ipsum"}, t), "input")
 	args := []string{"lorem", "bar", path}
 
 	config := fds.NewConfig()
-	config.Flags = map[string]bool{"insensitive": true}
+	config.Insensitive = true
 
-	var stdin, _ = os.Create(filepath.Join(tempDir, "stdin"))
+	stdin := newStdin("", t)
 	var stdout bytes.Buffer
 
-	err = execute(args, config, stdin, &stdout)
+	_, err := execute(context.Background(), args, config, stdin, &stdout)
 
 	if err != nil {
 		t.Errorf("execute() was not supposed to return error, but %q was returned", err)
@@ -222,28 +241,19 @@ func TestExecuteWithFileAndInsensitiveFlagSuccess(t *testing.T) {
 }
 
 func TestExecuteWithDirectory(t *testing.T) {
-	tempDir := t.TempDir()
+	tempDir := writeTestFiles(map[string]string{"input1": "lorem ipsum", "input2": "dolor sit amet"}, t)
 
 	path1 := filepath.Join(tempDir, "input1")
 	path2 := filepath.Join(tempDir, "input2")
 
-	file1, err := os.Create(path1)
-	file2, err := os.Create(path2)
-
-	file1.WriteString("lorem ipsum")
-	file1.Seek(0, io.SeekStart)
-	file2.WriteString("dolor sit amet")
-	file2.Seek(0, io.SeekStart)
-
 	args := []string{"lorem", "bar", tempDir}
 
 	config := fds.NewConfig()
-	config.Flags = map[string]bool{}
 
-	var stdin, _ = os.Create(filepath.Join(tempDir, "stdin"))
+	stdin := newStdin("", t)
 	var stdout bytes.Buffer
 
-	err = execute(args, config, stdin, &stdout)
+	_, err := execute(context.Background(), args, config, stdin, &stdout)
 
 	if err != nil {
 		t.Errorf("execute() was not supposed to return error, but %q was returned", err)
@@ -263,3 +273,295 @@ func TestExecuteWithDirectory(t *testing.T) {
 		t.Errorf("execute() result is %q, want %q", string(resultFile2), want2)
 	}
 }
+
+func TestExecuteWithDeleteLine(t *testing.T) {
+	path := filepath.Join(writeTestFiles(map[string]string{"input": "lorem\nipsum\nlorem\n"}, t), "input")
+
+	args := []string{"lorem", path}
+
+	config := fds.NewConfig()
+	config.Operations = fds.LineOperations{Delete: true}
+
+	stdin := newStdin("", t)
+	var stdout bytes.Buffer
+
+	_, err := execute(context.Background(), args, config, stdin, &stdout)
+
+	if err != nil {
+		t.Errorf("execute() was not supposed to return error, but %q was returned", err)
+	}
+
+	want := "ipsum\n"
+	result, _ := os.ReadFile(path)
+
+	if string(result) != want {
+		t.Errorf("execute() result is %q, want %q", string(result), want)
+	}
+}
+
+func TestExecuteExitStatus(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{name: "replaced", content: "lorem ipsum", want: fds.ExitSuccess},
+		{name: "nothing matched", content: "dolor sit amet", want: fds.ExitNoMatch},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(writeTestFiles(map[string]string{"input": tc.content}, t), "input")
+
+			config := fds.NewConfig()
+
+			stdin := newStdin("", t)
+			var stdout bytes.Buffer
+
+			status, err := execute(context.Background(), []string{"lorem", "bar", path}, config, stdin, &stdout)
+
+			if err != nil {
+				t.Errorf("execute() was not supposed to return error, but %q was returned", err)
+			}
+
+			if status != tc.want {
+				t.Errorf("execute() returned status %d, want %d", status, tc.want)
+			}
+		})
+	}
+}
+
+func TestExecuteOutputJSON(t *testing.T) {
+	tempDir := writeTestFiles(map[string]string{"input1": "lorem ipsum lorem", "input2": "dolor sit amet"}, t)
+
+	config := fds.NewConfig()
+	config.OutputFormat = fds.OutputJSON
+
+	stdin, _ := os.Open(os.DevNull)
+	defer stdin.Close()
+
+	var stdout bytes.Buffer
+
+	status, err := execute(context.Background(), []string{"lorem", "bar", tempDir}, config, stdin, &stdout)
+
+	if err != nil || status != fds.ExitSuccess {
+		t.Fatalf("execute() = %d, %v, want the files replaced in", status, err)
+	}
+
+	want := fmt.Sprintf(`{"files":[{"path":%q,"replaced":true,"replacements":2},{"path":%q,"replaced":false,"replacements":0}],"replacements":2}`+"\n",
+		filepath.Join(tempDir, "input1"), filepath.Join(tempDir, "input2"))
+
+	if stdout.String() != want {
+		t.Errorf("execute() printed %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestExecuteInterrupted(t *testing.T) {
+	path := filepath.Join(writeTestFiles(map[string]string{"input": "lorem ipsum"}, t), "input")
+
+	config := fds.NewConfig()
+
+	stdin := newStdin("", t)
+	var stdout bytes.Buffer
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := execute(ctx, []string{"lorem", "bar", path}, config, stdin, &stdout)
+
+	if code := fds.ExitCode(err); code != fds.ExitInterrupted {
+		t.Errorf("execute() returned error %v with code %d, want code %d", err, code, fds.ExitInterrupted)
+	}
+
+	if result, _ := os.ReadFile(path); string(result) != "lorem ipsum" {
+		t.Errorf("execute() replaced in %q after being interrupted", result)
+	}
+}
+
+func TestExecuteProgress(t *testing.T) {
+	tests := []struct {
+		name    string
+		confirm bool
+		verbose bool
+		want    bool
+	}{
+		{name: "Displayed", want: true},
+		{name: "Not displayed while confirming", confirm: true, want: false},
+		{name: "Not displayed with debug information", verbose: true, want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := writeTestFiles(map[string]string{"input": "lorem ipsum"}, t)
+
+			var progress bytes.Buffer
+
+			progressOutput = &progress
+			defer func() { progressOutput = nil }()
+
+			config := fds.NewConfig()
+			config.Confirm = tc.confirm
+			config.Verbose = tc.verbose
+
+			stdin, _ := os.Open(os.DevNull)
+			defer stdin.Close()
+
+			_, err := execute(context.Background(), []string{"foo", "bar", tempDir}, config, stdin, io.Discard)
+
+			if err != nil {
+				t.Fatalf("execute() was not supposed to return error, but %q was returned", err)
+			}
+
+			if displayed := strings.Contains(progress.String(), "1 files"); displayed != tc.want {
+				t.Errorf("execute() displayed progress %q, want displayed %v", progress.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestExecuteTUINotOnTerminal(t *testing.T) {
+	path := filepath.Join(writeTestFiles(map[string]string{"input": "lorem ipsum"}, t), "input")
+
+	tui = true
+	defer func() { tui = false }()
+
+	config := fds.NewConfig()
+
+	stdin, _ := os.Open(os.DevNull)
+	defer stdin.Close()
+
+	_, err := execute(context.Background(), []string{"lorem", "bar", path}, config, stdin, io.Discard)
+
+	if !errors.Is(err, fds.ErrReviewNotOnFile) {
+		t.Errorf("execute() returned error %v, want %v", err, fds.ErrReviewNotOnFile)
+	}
+
+	if result, _ := os.ReadFile(path); string(result) != "lorem ipsum" {
+		t.Errorf("execute() replaced in %q out of a terminal", result)
+	}
+}
+
+func TestExecuteConfirmStdinWithoutTerminal(t *testing.T) {
+	// no terminal can be opened at a path missing from the OS file system
+	terminalPath = filepath.Join(t.TempDir(), "missing")
+	defer func() { terminalPath = "/dev/tty" }()
+
+	config := fds.NewConfig()
+	config.Confirm = true
+
+	stdin := newStdin("lorem ipsum", t)
+
+	var stdout bytes.Buffer
+
+	_, err := execute(context.Background(), []string{"lorem", "foo"}, config, stdin, &stdout)
+
+	if !errors.Is(err, fds.ErrConfirmNotOnFile) {
+		t.Errorf("execute() returned error %v, want %v", err, fds.ErrConfirmNotOnFile)
+	}
+
+	if stdout.Len() > 0 {
+		t.Errorf("execute() wrote %q without a terminal to confirm on", stdout.String())
+	}
+}
+
+func TestExecuteAnswers(t *testing.T) {
+	// answers are recorded into, and replayed from, files on disk, as they are read by readAnswers
+	tempDir := t.TempDir()
+	record := filepath.Join(tempDir, "answers.jsonl")
+
+	tests := []struct {
+		name    string
+		answers string
+		record  string
+		stdin   string
+		want    string
+		wantErr error
+	}{
+		{name: "Recorded", record: record, stdin: "n\ny\ny\n", want: "lorem foo\nfoo"},
+		{name: "Replayed", answers: record, want: "lorem foo\nfoo"},
+		{name: "Sequence", answers: "yn y", want: "foo lorem\nfoo"},
+		{name: "Out of answers", answers: "n", want: "lorem lorem\nlorem", wantErr: fds.ErrMissingAnswer},
+		{name: "Neither a file nor a sequence", answers: "yes", want: "lorem lorem\nlorem", wantErr: fds.ErrAnswersRead},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// answers are recorded along with the path of the file, which is the same one when replaying them
+			path := filepath.Join(tempDir, "input")
+			os.WriteFile(path, []byte("lorem lorem\nlorem"), 0644)
+
+			answersFrom, recordAnswersTo = tc.answers, tc.record
+			defer func() { answersFrom, recordAnswersTo = "", "" }()
+
+			config := fds.NewConfig()
+			config.Confirm = true
+
+			// answers are typed through a pipe, as stdin holding content is replaced in instead
+			stdin, typed, _ := os.Pipe()
+			defer stdin.Close()
+
+			typed.WriteString(tc.stdin)
+			typed.Close()
+
+			_, err := execute(context.Background(), []string{"lorem", "foo", path}, config, stdin, io.Discard)
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("execute() returned error %v, want %v", err, tc.wantErr)
+			}
+
+			if result, _ := os.ReadFile(path); string(result) != tc.want {
+				t.Errorf("execute() replaced into %q, want %q", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestExecuteConfigShow(t *testing.T) {
+	defaults = fds.Defaults{Sources: map[string]string{"workers": "/home/user/.config/fds/config.toml", "ignore-globs": ".fds.toml"}}
+	ignoreGlobs, colorMode = fds.IgnoreGlobs{"vendor/**"}, fds.ColorNever
+	defer func() { defaults, ignoreGlobs, colorMode = fds.Defaults{}, nil, "" }()
+
+	config := fds.NewConfig()
+	config.Workers = 8
+	config.Insensitive = true
+
+	stdin, _ := os.Open(os.DevNull)
+	defer stdin.Close()
+
+	var stdout bytes.Buffer
+
+	status, err := execute(context.Background(), []string{"config", "show"}, config, stdin, &stdout)
+
+	if err != nil || status != fds.ExitSuccess {
+		t.Fatalf("execute() = %d, %v, want the configuration shown", status, err)
+	}
+
+	for _, want := range []string{
+		"workers = 8 ",
+		"# /home/user/.config/fds/config.toml\n",
+		`ignore-globs = ["vendor/**"] `,
+		`color = "never" `,
+		"insensitive = true ",
+		"context = 2 ",
+		"max-per-file = 0 ",
+		`eol = "" `,
+		`encoding = "" `,
+		"no-progress = false ",
+		`output-format = "text" `,
+		"# default\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("execute() printed %q, want it to contain %q", stdout.String(), want)
+		}
+	}
+
+	// content piped on stdin is not replaced in, nor does an empty pipe make the arguments invalid
+	for _, content := range []string{"config", ""} {
+		stdout.Reset()
+		status, err := execute(context.Background(), []string{"config", "show"}, config, newStdin(content, t), &stdout)
+
+		if err != nil || status != fds.ExitSuccess || !strings.Contains(stdout.String(), "workers = 8 ") {
+			t.Errorf("execute() = %d, %v, printing %q with %q piped, want the configuration shown", status, err, stdout.String(), content)
+		}
+	}
+}
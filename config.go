@@ -1,13 +1,162 @@
 package fds
 
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sync/atomic"
+)
+
+// Limits caps the number of replacements performed. A zero value means unlimited
+type Limits struct {
+	PerLine int
+	PerFile int
+	Total   int
+}
+
+/**
+ * Config holds the options replacers consume, each of them set by a flag of the command line. Options are checked
+ * against each other by Config.Validate, and against the arguments by Validate
+ */
 type Config struct {
-	Flags   map[string]bool
-	Workers int
+	// Literal treats the search pattern as a plain string instead of as a regular expression
+	Literal bool
+
+	// Insensitive ignores case when matching. It cannot be used along with Literal
+	Insensitive bool
+
+	// PreserveCase matches ignoring case, adapting the case of the replacement to the one of each match
+	PreserveCase bool
+
+	// Confirm asks before each replacement through Options.Confirm, replacing in a single file at a time
+	Confirm bool
+
+	// Verbose logs debug information into Logger
+	Verbose bool
+
+	// Review is set by NewReview and Review.Apply, which answer the prompts themselves, for the matches of files only
+	Review bool
+
+	Workers    int
+	Limits     Limits
+	Selection  Selection
+	Operations LineOperations
+
+	// Context is the number of lines shown before and after the line of a match being confirmed
+	Context int
+
+	// LineEnding normalises the line endings of the files written. Empty keeps the original ones
+	LineEnding LineEnding
+
+	// Encoding is assumed for files without a BOM. When not set, UTF-8 is assumed
+	Encoding Encoding
+
+	// OutputFormat is the format WriteReport writes in. Empty stands for OutputText
+	OutputFormat OutputFormat
+
+	// FileSystem is what files are read and written through. When not set, the one of the operating system is used
+	FileSystem FileSystem
+
+	// Logger receives the messages logged when Verbose is set. When not set, the standard logger is used
+	Logger *log.Logger
+
+	// replaced counts the replacements performed across all files, shared by every worker
+	replaced *atomic.Int64
 }
 
 func NewConfig() Config {
 	return Config{
-		Flags:   map[string]bool{"confirm": false, "insensitive": false, "literal": false, "verbose": false},
-		Workers: 4,
+		Workers:      4,
+		Context:      2,
+		OutputFormat: OutputText,
+	}
+}
+
+/**
+ * Validate checks the options on their own: Literal and Insensitive are mutually exclusive, as are Review and line
+ * operations, and OutputJSON and Confirm, whose prompts would be mixed with the report. No number may be negative, and
+ * LineEnding and OutputFormat must be one of their constants. Checks involving the arguments, such as line selections
+ * not being usable on stdin, are left to the package function Validate(args, config), which calls this one first
+ */
+func (c Config) Validate() error {
+	if c.Literal && c.Insensitive {
+		return NewLiteralInsensitiveError()
+	}
+
+	numbers := []struct {
+		option string
+		value  int
+	}{
+		{option: "workers", value: c.Workers},
+		{option: "context", value: c.Context},
+		{option: "max-per-line", value: c.Limits.PerLine},
+		{option: "max-per-file", value: c.Limits.PerFile},
+		{option: "max-total", value: c.Limits.Total},
+	}
+
+	for _, number := range numbers {
+		if number.value < 0 {
+			return NewInvalidOptionError(number.option, fmt.Sprintf("%d cannot be negative", number.value))
+		}
+	}
+
+	switch c.LineEnding {
+	case "", LF, CRLF, CR:
+	default:
+		return NewInvalidOptionError("eol", fmt.Sprintf("%q is not a line ending", string(c.LineEnding)))
 	}
+
+	switch c.OutputFormat {
+	case "", OutputText, OutputJSON:
+	default:
+		return NewInvalidOptionError("output-format", fmt.Sprintf("%q is not an output format", string(c.OutputFormat)))
+	}
+
+	// reviews only select matches, while line operations are confirmed line by line
+	if c.Review && c.Operations.IsSet() {
+		return NewInvalidOptionError("tui", "cannot be used along with [--delete-line, --insert-before, --insert-after]")
+	}
+
+	// reviews answer the prompts themselves, instead of printing them
+	if c.OutputFormat == OutputJSON && c.Confirm && !c.Review {
+		return NewInvalidOptionError("output-format", "json cannot be used along with [-c, --confirm, --answers, --record-answers], as prompts are printed on stdout")
+	}
+
+	return nil
+}
+
+// workers tells how many files are replaced in at once: a single one when confirming, one per CPU when not set
+func (c Config) workers() int {
+	if c.Confirm {
+		return 1
+	}
+
+	if c.Workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	return c.Workers
+}
+
+func (c Config) fileSystem() FileSystem {
+	if c.FileSystem == nil {
+		return OSFileSystem{}
+	}
+
+	return c.FileSystem
+}
+
+// logf logs only when Verbose is set
+func (c Config) logf(format string, v ...any) {
+	if !c.Verbose {
+		return
+	}
+
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+
+		return
+	}
+
+	log.Printf(format, v...)
 }
@@ -0,0 +1,224 @@
+package fds
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fatih/color"
+)
+
+// ProjectConfigFile is the name of the configuration file of a project, looked for in the directory fds runs in and its parents
+const ProjectConfigFile = ".fds.toml"
+
+// ColorMode tells when output is colored: on terminals only, always or never
+type ColorMode string
+
+const (
+	ColorAuto   ColorMode = "auto"
+	ColorAlways ColorMode = "always"
+	ColorNever  ColorMode = "never"
+)
+
+func (m *ColorMode) String() string {
+	return string(*m)
+}
+
+func (m *ColorMode) Type() string {
+	return "auto|always|never"
+}
+
+func (m *ColorMode) Set(value string) error {
+	switch mode := ColorMode(strings.ToLower(value)); mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		*m = mode
+	default:
+		return fmt.Errorf("%q is not a valid color mode. Use auto, always or never", value)
+	}
+
+	return nil
+}
+
+// UnmarshalText lets color modes be read from configuration files, validated as they are on the command line
+func (m *ColorMode) UnmarshalText(text []byte) error {
+	return m.Set(string(text))
+}
+
+// Apply colors output or not. In auto mode, it is colored when stdout is a terminal and NO_COLOR is not set
+func (m ColorMode) Apply() {
+	switch m {
+	case ColorAlways:
+		color.NoColor = false
+	case ColorNever:
+		color.NoColor = true
+	}
+}
+
+/**
+ * Defaults are the settings read from configuration files, which flags fall back to when not supplied. Settings are
+ * named after the long flag setting them:
+ *
+ *	workers = 8
+ *	ignore-globs = ["vendor/**", "node_modules/**"]
+ *	preserve-case = true
+ *	color = "never"
+ */
+type Defaults struct {
+	Workers      int          `toml:"workers"`
+	Context      int          `toml:"context"`
+	IgnoreGlobs  []string     `toml:"ignore-globs"`
+	Color        ColorMode    `toml:"color"`
+	Literal      bool         `toml:"literal"`
+	Insensitive  bool         `toml:"insensitive"`
+	PreserveCase bool         `toml:"preserve-case"`
+	Confirm      bool         `toml:"confirm"`
+	Verbose      bool         `toml:"verbose"`
+	MaxPerLine   int          `toml:"max-per-line"`
+	MaxPerFile   int          `toml:"max-per-file"`
+	MaxTotal     int          `toml:"max-total"`
+	EOL          LineEnding   `toml:"eol"`
+	Encoding     Encoding     `toml:"encoding"`
+	NoProgress   bool         `toml:"no-progress"`
+	OutputFormat OutputFormat `toml:"output-format"`
+
+	// Sources maps the name of each setting read to the file it was last read from
+	Sources map[string]string `toml:"-"`
+}
+
+/**
+ * LoadDefaults reads the configuration file of the user, then the one of the project `dir` belongs to, the settings of
+ * the latter taking precedence. Settings missing from both keep the defaults of NewConfig
+ */
+func LoadDefaults(dir string) (Defaults, error) {
+	config := NewConfig()
+	defaults := Defaults{Workers: config.Workers, Context: config.Context, Color: ColorAuto, OutputFormat: config.OutputFormat, Sources: map[string]string{}}
+
+	for _, path := range []string{userConfigFile(), projectConfigFile(dir)} {
+		if path == "" {
+			continue
+		}
+
+		if err := defaults.read(path); err != nil {
+			return defaults, err
+		}
+	}
+
+	return defaults, nil
+}
+
+// Source tells where the setting `name` comes from: the configuration file setting it, "default" otherwise
+func (d Defaults) Source(name string) string {
+	if path, ok := d.Sources[name]; ok {
+		return path
+	}
+
+	return "default"
+}
+
+// read overrides the settings found in the configuration file `path`, if there is one
+func (d *Defaults) read(path string) error {
+	metadata, err := toml.DecodeFile(path, d)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return NewConfigFileError(path, err)
+	}
+
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		return NewConfigFileError(path, fmt.Errorf("unknown setting %q", undecoded[0].String()))
+	}
+
+	for _, key := range metadata.Keys() {
+		d.Sources[key.String()] = path
+	}
+
+	return nil
+}
+
+// userConfigFile is where the configuration file of the user is, under $XDG_CONFIG_HOME or ~/.config
+func userConfigFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+
+	if dir == "" {
+		home, err := os.UserHomeDir()
+
+		if err != nil {
+			return ""
+		}
+
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "fds", "config.toml")
+}
+
+// projectConfigFile finds the closest ProjectConfigFile, in `dir` or its parents
+func projectConfigFile(dir string) string {
+	for {
+		path := filepath.Join(dir, ProjectConfigFile)
+
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+
+		if parent == dir {
+			return ""
+		}
+
+		dir = parent
+	}
+}
+
+// Setting is a setting of the configuration in effect, along with where its value comes from
+type Setting struct {
+	Name   string
+	Value  any
+	Source string
+}
+
+// PrintSettings prints `settings` in the format of configuration files, each along with its source as a comment
+func PrintSettings(writer io.Writer, settings []Setting) error {
+	table := tabwriter.NewWriter(writer, 0, 4, 1, ' ', 0)
+
+	for _, setting := range settings {
+		fmt.Fprintf(table, "%s = %s\t# %s\n", setting.Name, tomlValue(setting.Value), setting.Source)
+	}
+
+	return table.Flush()
+}
+
+func tomlValue(value any) string {
+	switch value := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", value)
+	case ColorMode:
+		return fmt.Sprintf("%q", value)
+	case OutputFormat:
+		return fmt.Sprintf("%q", value)
+	case LineEnding:
+		return fmt.Sprintf("%q", value.String())
+	case Encoding:
+		return fmt.Sprintf("%q", value.String())
+	case []string:
+		quoted := make([]string, len(value))
+
+		for i, item := range value {
+			quoted[i] = fmt.Sprintf("%q", item)
+		}
+
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	return fmt.Sprint(value)
+}
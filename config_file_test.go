@@ -0,0 +1,167 @@
+package fds
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfigFile(path, content string, t *testing.T) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create directory of %s: %s", path, err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %s", path, err)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	// configuration files are looked up on the OS file system, from $XDG_CONFIG_HOME and the parents of the directory
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+
+	userFile := filepath.Join(home, "fds", "config.toml")
+	projectFile := filepath.Join(project, ProjectConfigFile)
+
+	writeConfigFile(userFile, "workers = 8\nignore-globs = [\"vendor/**\", \".git/**\"]\ncolor = \"never\"\ninsensitive = true\nmax-per-line = 1\neol = \"crlf\"\n", t)
+	writeConfigFile(projectFile, "# closer to the files replaced in\nignore-globs = [\"dist/**\"]\ninsensitive = false\nverbose = true\nencoding = \"ISO-8859-1\"\nno-progress = true\noutput-format = \"json\"\n", t)
+
+	// the project file is found from any directory of the project
+	dir := filepath.Join(project, "src", "pkg")
+	os.MkdirAll(dir, 0o755)
+
+	defaults, err := LoadDefaults(dir)
+
+	if err != nil {
+		t.Fatalf("LoadDefaults() returned an unexpected error '%s'", err)
+	}
+
+	var encoding Encoding
+	encoding.Set("ISO-8859-1")
+
+	want := Defaults{
+		Workers:      8,
+		Context:      2,
+		IgnoreGlobs:  []string{"dist/**"},
+		Color:        ColorNever,
+		Verbose:      true,
+		MaxPerLine:   1,
+		EOL:          CRLF,
+		Encoding:     encoding,
+		NoProgress:   true,
+		OutputFormat: OutputJSON,
+		Sources: map[string]string{
+			"workers":       userFile,
+			"color":         userFile,
+			"max-per-line":  userFile,
+			"eol":           userFile,
+			"ignore-globs":  projectFile,
+			"insensitive":   projectFile,
+			"verbose":       projectFile,
+			"encoding":      projectFile,
+			"no-progress":   projectFile,
+			"output-format": projectFile,
+		},
+	}
+
+	if !reflect.DeepEqual(defaults, want) {
+		t.Errorf("LoadDefaults() = %+v, want %+v", defaults, want)
+	}
+
+	if source := defaults.Source("context"); source != "default" {
+		t.Errorf(`Source("context") = %q, want "default"`, source)
+	}
+}
+
+func TestLoadDefaults_NoFiles(t *testing.T) {
+	// empty directories on disk, as LoadDefaults reads no other file system
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	defaults, err := LoadDefaults(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("LoadDefaults() returned an unexpected error '%s'", err)
+	}
+
+	config := NewConfig()
+
+	if defaults.Workers != config.Workers || defaults.Context != config.Context || defaults.Color != ColorAuto || defaults.OutputFormat != OutputText || len(defaults.Sources) != 0 {
+		t.Errorf("LoadDefaults() = %+v, want the defaults of NewConfig", defaults)
+	}
+}
+
+func TestLoadDefaults_Invalid(t *testing.T) {
+	// each file is written on disk, where LoadDefaults looks configuration files up
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "Unknown setting", content: "worker = 8\n"},
+		{name: "Invalid color", content: "color = \"sometimes\"\n"},
+		{name: "Wrong type", content: "workers = \"many\"\n"},
+		{name: "Invalid line ending", content: "eol = \"lfcr\"\n"},
+		{name: "Invalid encoding", content: "encoding = \"klingon\"\n"},
+		{name: "Invalid output format", content: "output-format = \"xml\"\n"},
+		{name: "Invalid TOML", content: "workers =\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+			project := t.TempDir()
+			writeConfigFile(filepath.Join(project, ProjectConfigFile), tc.content, t)
+
+			_, err := LoadDefaults(project)
+
+			if !errors.Is(err, ErrConfigFile) {
+				t.Errorf("LoadDefaults() returned error %v, want ErrConfigFile", err)
+			}
+		})
+	}
+}
+
+func TestColorMode_Set(t *testing.T) {
+	var mode ColorMode
+
+	if err := mode.Set("Always"); err != nil || mode != ColorAlways {
+		t.Errorf(`Set("Always") = %q, %v, want ColorAlways`, mode, err)
+	}
+
+	if err := mode.Set("sometimes"); err == nil {
+		t.Errorf(`Set("sometimes") expects error, none returned`)
+	}
+}
+
+func TestPrintSettings(t *testing.T) {
+	var output bytes.Buffer
+
+	settings := []Setting{
+		{Name: "workers", Value: 8, Source: "/home/user/.config/fds/config.toml"},
+		{Name: "ignore-globs", Value: []string{"vendor/**", "dist/**"}, Source: ".fds.toml"},
+		{Name: "color", Value: ColorAuto, Source: "default"},
+		{Name: "literal", Value: true, Source: "flag"},
+		{Name: "eol", Value: CRLF, Source: "flag"},
+		{Name: "encoding", Value: Encoding{}, Source: "default"},
+	}
+
+	if err := PrintSettings(&output, settings); err != nil {
+		t.Fatalf("PrintSettings() returned an unexpected error '%s'", err)
+	}
+
+	want := `workers = 8                             # /home/user/.config/fds/config.toml
+ignore-globs = ["vendor/**", "dist/**"] # .fds.toml
+color = "auto"                          # default
+literal = true                          # flag
+eol = "crlf"                            # flag
+encoding = ""                           # default
+`
+
+	if output.String() != want {
+		t.Errorf("PrintSettings() printed\n%s\nwant\n%s", output.String(), want)
+	}
+}
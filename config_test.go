@@ -0,0 +1,71 @@
+package fds
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestConfig_Workers(t *testing.T) {
+	var tests = []struct {
+		name    string
+		workers int
+		confirm bool
+		want    int
+	}{
+		{name: "set", workers: 8, want: 8},
+		{name: "zero means one per CPU", workers: 0, want: runtime.GOMAXPROCS(0)},
+		{name: "single one when confirming", workers: 8, confirm: true, want: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Workers = tc.workers
+			config.Confirm = tc.confirm
+
+			if result := config.workers(); result != tc.want {
+				t.Errorf("workers() = %d, want %d", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	var tests = []struct {
+		name    string
+		config  func(config *Config)
+		wantErr error
+	}{
+		{name: "defaults", config: func(config *Config) {}},
+		{name: "zero workers", config: func(config *Config) { config.Workers = 0 }},
+		{name: "literal and insensitive", config: func(config *Config) { config.Literal, config.Insensitive = true, true }, wantErr: ErrLiteralInsensitive},
+		{name: "literal and preserve case", config: func(config *Config) { config.Literal, config.PreserveCase = true, true }},
+		{name: "negative workers", config: func(config *Config) { config.Workers = -1 }, wantErr: ErrInvalidOption},
+		{name: "negative context", config: func(config *Config) { config.Context = -2 }, wantErr: ErrInvalidOption},
+		{name: "negative limit", config: func(config *Config) { config.Limits.Total = -1 }, wantErr: ErrInvalidOption},
+		{name: "unknown line ending", config: func(config *Config) { config.LineEnding = "\n\n" }, wantErr: ErrInvalidOption},
+		{name: "unknown output format", config: func(config *Config) { config.OutputFormat = "xml" }, wantErr: ErrInvalidOption},
+		{name: "unset output format", config: func(config *Config) { config.OutputFormat = "" }},
+		{name: "json and confirm", config: func(config *Config) { config.OutputFormat, config.Confirm = OutputJSON, true }, wantErr: ErrInvalidOption},
+		{name: "review and line operations", config: func(config *Config) { config.Review, config.Operations.Delete = true, true }, wantErr: ErrInvalidOption},
+		{name: "json and review", config: func(config *Config) { config.OutputFormat, config.Confirm, config.Review = OutputJSON, true, true }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config := NewConfig()
+			tc.config(&config)
+
+			err := config.Validate()
+
+			if tc.wantErr == nil && err != nil {
+				t.Errorf("Validate() returned an unexpected error '%s'", err)
+			}
+
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("Validate() returned error %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -4,32 +4,234 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"os/exec"
 	"slices"
+	"strings"
+
+	"golang.org/x/term"
 )
 
 const (
-	enter = 10
+	enter             = 10
+	carriageReturn    = 13
+	interrupt         = 3
+	endOfTransmission = 4
 )
 
 type ConfirmAnswer rune
 
-func Confirm(stdin io.Reader, text string, valid []rune) (rune, error) {
-	var input rune
-	var err error
+/**
+ * Prompt is what is asked to be confirmed: either a single match to be substituted, the line operations to be
+ * performed on a line, or, when neither is set, any other question in `Text`, such as overwriting a file modified
+ * in the meantime. The answer must be one of `Valid`
+ */
+type Prompt struct {
+	File       string
+	LineNumber int
+
+	// Index is the position of the match among the ones of the file, from 1, when asking about a match
+	Index int
+
+	Match      *MatchString
+	Operations *LineOperations
+	Line       string
+
+	// ContextBefore and ContextAfter are the lines around the one of the match, as many as Config.Context
+	ContextBefore []string
+	ContextAfter  []string
+
+	Text  string
+	Valid []rune
+
+	// Notice tells about the previous answer when it could not be followed, such as undoing with nothing to undo
+	Notice string
+}
+
+/**
+ * Answer is what a ConfirmFunc answers: one of the Confirm* choices, along with the replacement typed in when
+ * answering ConfirmEdit, or the pattern to jump to when answering ConfirmJump
+ */
+type Answer struct {
+	Choice      rune
+	Replacement string
+	Pattern     string
+}
+
+// ConfirmFunc answers a Prompt. Answering ConfirmQuit stops asking, ConfirmAll accepts the remaining matches
+type ConfirmFunc func(prompt Prompt) (Answer, error)
+
+/**
+ * NewTerminalConfirm asks on `stdout`, reading the answers from `stdin`. When `stdin` is a terminal, choices are
+ * answered with a single key, without Enter
+ */
+func NewTerminalConfirm(stdin io.Reader, stdout io.Writer) ConfirmFunc {
+	// a single reader, so that nothing read ahead of an answer is lost for the next one
+	reader := newAnswerReader(stdin)
 
-	reader := bufio.NewReader(stdin)
+	return func(prompt Prompt) (Answer, error) {
+		switch {
+		case prompt.Match != nil:
+			return ConfirmMatch(prompt, reader, stdout)
+		case prompt.Operations != nil:
+			choice, err := ConfirmLineOperation(*prompt.Operations, prompt.Line, prompt.File, prompt.LineNumber, reader, stdout)
 
-	for !slices.Contains(valid, input) {
-		if input != enter {
-			fmt.Print(text + ": ")
+			return Answer{Choice: choice}, err
 		}
 
-		input, _, err = reader.ReadRune()
+		choice, err := Confirm(reader, stdout, prompt.Text, prompt.Valid)
+
+		return Answer{Choice: choice}, err
+	}
+}
+
+/**
+ * Confirm asks `text` until one of the `valid` runes is answered. On a terminal, a single key answers, Ctrl+C
+ * interrupts and Ctrl+D ends the input. Otherwise, answers are read from lines, where line breaks are not answers
+ */
+func Confirm(stdin io.Reader, stdout io.Writer, text string, valid []rune) (rune, error) {
+	reader := newAnswerReader(stdin)
+
+	fmt.Fprint(stdout, text+": ")
+
+	for {
+		input, raw, err := reader.readKey()
 
 		if err != nil {
 			return 0, err
 		}
+
+		if raw && input == interrupt {
+			fmt.Fprintln(stdout)
+
+			return 0, NewInterruptedError(nil)
+		}
+
+		if raw && input == endOfTransmission {
+			return 0, io.EOF
+		}
+
+		if slices.Contains(valid, input) {
+			// keys are not echoed in raw mode
+			if raw {
+				fmt.Fprintln(stdout, string(input))
+			}
+
+			return input, nil
+		}
+
+		if !raw && input != enter && input != carriageReturn {
+			fmt.Fprint(stdout, text+": ")
+		}
+	}
+}
+
+/**
+ * answerReader reads the answers to prompts. When reading from a terminal, keys are read in raw mode, as soon as they
+ * are pressed, while lines, such as the replacements typed in, are still read in line mode, echoed and editable
+ */
+type answerReader struct {
+	*bufio.Reader
+
+	// source is what is read from, which an editor reads from too when it is a file, such as a terminal
+	source io.Reader
+
+	// terminal is the file descriptor of the terminal read from, -1 when not reading from one
+	terminal int
+}
+
+// newAnswerReader reads from `stdin` through the reader it already is, if it is one, so that nothing it read ahead is lost
+func newAnswerReader(stdin io.Reader) *answerReader {
+	if reader, ok := stdin.(*answerReader); ok {
+		return reader
+	}
+
+	terminal := -1
+
+	if file, ok := stdin.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		terminal = int(file.Fd())
+	}
+
+	return &answerReader{Reader: bufio.NewReader(stdin), source: stdin, terminal: terminal}
+}
+
+// readKey reads a rune, telling whether it was read in raw mode, in which case the terminal is restored afterwards
+func (r *answerReader) readKey() (char rune, raw bool, err error) {
+	if r.terminal >= 0 {
+		if state, err := term.MakeRaw(r.terminal); err == nil {
+			defer term.Restore(r.terminal, state)
+
+			raw = true
+		}
+	}
+
+	char, _, err = r.ReadRune()
+
+	return char, raw, err
+}
+
+// readAnswerLine reads a line typed in after an answer, leaving out the line break of the answer itself
+func readAnswerLine(reader *answerReader, stdout io.Writer, text string) (string, error) {
+	// keys answered in raw mode are not followed by one, which would be waited for
+	if reader.Buffered() > 0 {
+		if next, _ := reader.Peek(1); next[0] == enter {
+			reader.ReadByte()
+		}
+	}
+
+	fmt.Fprint(stdout, text+": ")
+
+	line, err := reader.ReadString(enter)
+
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+/**
+ * editInEditor lets `initial` be edited in the editor set in $EDITOR, which may hold arguments, returning the text
+ * saved without its trailing line break. The editor runs on the terminal prompts are asked on, rather than on the
+ * standard streams, which may be piped
+ */
+func editInEditor(editor, initial string, reader *answerReader, stdout io.Writer) (string, error) {
+	file, err := os.CreateTemp("", "fds-replacement-*.txt")
+
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(initial + "\n")
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	args := append(strings.Fields(editor), file.Name())
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = stdout, stdout
+
+	// anything else read from would be partly read ahead already, so the editor reads from nothing instead
+	if file, ok := reader.source.(*os.File); ok {
+		cmd.Stdin = file
+	}
+
+	if err = cmd.Run(); err != nil {
+		return "", fmt.Errorf("Editor %q failed: %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+
+	if err != nil {
+		return "", err
 	}
 
-	return input, nil
+	return strings.TrimRight(string(edited), "\r\n"), nil
 }
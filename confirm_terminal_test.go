@@ -0,0 +1,180 @@
+//go:build linux
+
+package fds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openTestTerminal opens a pseudo-terminal, returning the side written to as if typing and the terminal itself
+func openTestTerminal(t *testing.T) (keyboard, terminal *os.File) {
+	keyboard, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+
+	if err != nil {
+		t.Skipf("Pseudo-terminals are not available: %s", err)
+	}
+
+	t.Cleanup(func() { keyboard.Close() })
+
+	if err := unix.IoctlSetPointerInt(int(keyboard.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatalf("Failed to unlock pseudo-terminal: %s", err)
+	}
+
+	number, err := unix.IoctlGetInt(int(keyboard.Fd()), unix.TIOCGPTN)
+
+	if err != nil {
+		t.Fatalf("Failed to get pseudo-terminal number: %s", err)
+	}
+
+	terminal, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|unix.O_NOCTTY, 0)
+
+	if err != nil {
+		t.Fatalf("Failed to open pseudo-terminal: %s", err)
+	}
+
+	t.Cleanup(func() { terminal.Close() })
+
+	return keyboard, terminal
+}
+
+// typeWhenRaw types `keys` once `terminal` is in raw mode, as control keys typed in line mode are interpreted
+func typeWhenRaw(keyboard, terminal *os.File, keys string) {
+	go func() {
+		for {
+			termios, err := unix.IoctlGetTermios(int(terminal.Fd()), unix.TCGETS)
+
+			if err != nil || termios.Lflag&unix.ICANON == 0 {
+				break
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+
+		keyboard.WriteString(keys)
+	}()
+}
+
+func TestConfirm_Terminal(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		want    rune
+		wantErr error
+	}{
+		{name: "Single key", keys: "y", want: 'y'},
+		{name: "Invalid keys ignored", keys: "x\rn", want: 'n'},
+		{name: "Ctrl+C", keys: "\x03", wantErr: ErrInterrupted},
+		{name: "Ctrl+D", keys: "\x04", wantErr: io.EOF},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			keyboard, terminal := openTestTerminal(t)
+			typeWhenRaw(keyboard, terminal, tc.keys)
+
+			var stdout strings.Builder
+
+			result, err := Confirm(terminal, &stdout, "text", []rune{'y', 'n'})
+
+			if !errors.Is(err, tc.wantErr) || result != tc.want {
+				t.Errorf("Confirm() = %q, %v, want %q, %v", result, err, tc.want, tc.wantErr)
+			}
+
+			if tc.wantErr == nil && stdout.String() != "text: "+string(tc.want)+"\n" {
+				t.Errorf("Confirm() printed %q, want the prompt once and the key answered", stdout.String())
+			}
+
+			termios, err := unix.IoctlGetTermios(int(terminal.Fd()), unix.TCGETS)
+
+			if err != nil || termios.Lflag&unix.ICANON == 0 || termios.Lflag&unix.ECHO == 0 {
+				t.Errorf("Confirm() left the terminal in raw mode")
+			}
+		})
+	}
+}
+
+func TestConfirmMatch_TerminalEditsInLineMode(t *testing.T) {
+	t.Setenv("EDITOR", "")
+
+	keyboard, terminal := openTestTerminal(t)
+	typeWhenRaw(keyboard, terminal, "e")
+
+	prompt := Prompt{File: "file", LineNumber: 1, Match: &MatchString{Search: "foo", Replace: "bar"}}
+	answers := make(chan Answer)
+
+	go func() {
+		answer, _ := ConfirmMatch(prompt, terminal, terminal)
+		answers <- answer
+	}()
+
+	// typed in once the line is being read, as the line discipline only gathers lines in line mode
+	buffer := make([]byte, 256)
+	var echoed string
+
+	for !strings.Contains(echoed, "Replace with") {
+		n, err := keyboard.Read(buffer)
+
+		if err != nil {
+			t.Fatalf("Failed to read from pseudo-terminal: %s", err)
+		}
+
+		echoed += string(buffer[:n])
+	}
+
+	keyboard.WriteString("baz\n")
+
+	if answer := <-answers; answer.Choice != ConfirmEdit || answer.Replacement != "baz" {
+		t.Errorf("ConfirmMatch() = %+v, want %c with replacement %q", answer, ConfirmEdit, "baz")
+	}
+}
+
+func TestRun_TerminalInterruptedPartway(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations LineOperations
+	}{
+		{name: "Matches"},
+		{name: "Line operations", operations: LineOperations{Delete: true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			keyboard, terminal := openTestTerminal(t)
+			fileSystem := newTestFileSystem(map[string]string{"input": "foo one\nfoo two\n"}, t)
+
+			config := NewConfig()
+			config.FileSystem = fileSystem
+			config.Confirm = true
+			config.Operations = tc.operations
+
+			// the first match is accepted, then Ctrl-C is pressed on the second one
+			typeWhenRaw(keyboard, terminal, "y\x03")
+
+			replace := "bar"
+
+			if tc.operations.IsSet() {
+				replace = ""
+			}
+
+			options := Options{Search: "foo", Replace: replace, Paths: []string{"input"}, Config: config, Confirm: NewTerminalConfirm(terminal, terminal)}
+			_, err := Run(context.Background(), options)
+
+			if !errors.Is(err, ErrInterrupted) || ExitCode(err) != ExitInterrupted {
+				t.Errorf("Run() returned error %v, want it interrupted", err)
+			}
+
+			if result, _ := fileSystem.ReadFile("input"); string(result) != "foo one\nfoo two\n" {
+				t.Errorf("Run() replaced in %q once interrupted, want it untouched", result)
+			}
+		})
+	}
+}
@@ -2,36 +2,15 @@ package fds
 
 import (
 	"io"
-	"os"
-	"path"
+	"strings"
 	"testing"
 )
 
-func createFile(tempDir, inputContent string, t *testing.T) *os.File {
-	var file *os.File
-
-	file, err := os.Create(path.Join(tempDir, "input"))
-
-	if err != nil {
-		t.Fatalf("Failed to open input file")
-	}
-
-	_, err = file.WriteString(inputContent)
-
-	if err != nil {
-		t.Fatalf("Failed to write input file")
-	}
-
-	file.Seek(0, io.SeekStart)
-
-	return file
-}
-
 func TestConfirm_Valid(t *testing.T) {
-	stdin := createFile(t.TempDir(), "y", t)
+	stdin := strings.NewReader("y")
 
 	want := 'y'
-	result, err := Confirm(stdin, "text", []rune{'y', 'n'})
+	result, err := Confirm(stdin, io.Discard, "text", []rune{'y', 'n'})
 
 	if err != nil {
 		t.Fatalf("Confirm() does not expect error, got error %s", err)
@@ -43,11 +22,51 @@ func TestConfirm_Valid(t *testing.T) {
 }
 
 func TestConfirm_Invalid(t *testing.T) {
-	stdin := createFile(t.TempDir(), "*", t)
+	stdin := strings.NewReader("*")
 
-	_, err := Confirm(stdin, "text", []rune{'y', 'n'})
+	_, err := Confirm(stdin, io.Discard, "text", []rune{'y', 'n'})
 
 	if err == nil {
 		t.Fatalf("Confirm() expects error, did not get error %s", err)
 	}
 }
+
+func TestNewTerminalConfirm_KeepsInputReadAhead(t *testing.T) {
+	confirm := NewTerminalConfirm(strings.NewReader("y\nn\n"), io.Discard)
+
+	for _, want := range []rune{ConfirmYes, ConfirmNo} {
+		answer, err := confirm(Prompt{Text: "text", Valid: []rune{ConfirmYes, ConfirmNo}})
+
+		if err != nil || answer.Choice != want {
+			t.Errorf("NewTerminalConfirm() answered %c with error %v, want %c", answer.Choice, err, want)
+		}
+	}
+}
+
+func TestConfirm_LineBreaks(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		prompts int
+	}{
+		{name: "Answer", input: "y\n", prompts: 1},
+		{name: "Line breaks before the answer", input: "\n\r\ny\n", prompts: 1},
+		{name: "Invalid answer", input: "x\ny\n", prompts: 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout strings.Builder
+
+			result, err := Confirm(strings.NewReader(tc.input), &stdout, "text", []rune{'y', 'n'})
+
+			if err != nil || result != 'y' {
+				t.Fatalf("Confirm() = %c, %v, want y", result, err)
+			}
+
+			if prompts := strings.Count(stdout.String(), "text: "); prompts != tc.prompts {
+				t.Errorf("Confirm() prompted %d times, want %d, output %q", prompts, tc.prompts, stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package fds
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Encoding is the text encoding files are read and written in, as supplied in `--encoding`. Ex. UTF-16LE, ISO-8859-1
+type Encoding struct {
+	name     string
+	encoding encoding.Encoding
+}
+
+func (e *Encoding) String() string {
+	return e.name
+}
+
+func (e *Encoding) Type() string {
+	return "encoding"
+}
+
+func (e *Encoding) Set(value string) error {
+	found, err := ianaindex.IANA.Encoding(value)
+
+	if err != nil || found == nil {
+		return fmt.Errorf("%q is not a supported encoding", value)
+	}
+
+	*e = Encoding{name: value, encoding: found}
+
+	return nil
+}
+
+// UnmarshalText lets encodings be read from configuration files, where an empty one is left unset
+func (e *Encoding) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*e = Encoding{}
+
+		return nil
+	}
+
+	return e.Set(string(text))
+}
+
+func (e Encoding) IsSet() bool {
+	return e.encoding != nil
+}
+
+type byteOrderMark struct {
+	bom      []byte
+	encoding encoding.Encoding
+}
+
+// byteOrderMarks are sniffed in this order, as the UTF-8 one cannot be mistaken for the others
+var byteOrderMarks = []byteOrderMark{
+	{bom: []byte{0xEF, 0xBB, 0xBF}, encoding: unicode.UTF8},
+	{bom: []byte{0xFF, 0xFE}, encoding: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+	{bom: []byte{0xFE, 0xFF}, encoding: unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+}
+
+// textEncoding is the encoding a file was found in, used to write it back in the same encoding
+type textEncoding struct {
+	encoding encoding.Encoding
+	bom      []byte
+}
+
+func (t textEncoding) String() string {
+	if t.bom != nil {
+		return fmt.Sprintf("%s with BOM", t.encoding)
+	}
+
+	return fmt.Sprint(t.encoding)
+}
+
+func (t textEncoding) isUTF8() bool {
+	return t.encoding == unicode.UTF8
+}
+
+/**
+ * decodeInput reads the whole input and decodes it into UTF-8. The encoding is detected from the BOM, when there is
+ * one. Otherwise, `fallback` is used if set, or UTF-8 is assumed
+ */
+func decodeInput(input io.Reader, fallback Encoding) ([]byte, textEncoding, error) {
+	content, err := readInput(input)
+
+	if err != nil {
+		return nil, textEncoding{}, err
+	}
+
+	detected := textEncoding{encoding: unicode.UTF8}
+
+	if fallback.IsSet() {
+		detected.encoding = fallback.encoding
+	}
+
+	for _, mark := range byteOrderMarks {
+		if bytes.HasPrefix(content, mark.bom) {
+			detected = textEncoding{encoding: mark.encoding, bom: mark.bom}
+			content = content[len(mark.bom):]
+
+			break
+		}
+	}
+
+	if detected.isUTF8() {
+		return content, detected, nil
+	}
+
+	decoded, err := detected.encoding.NewDecoder().Bytes(content)
+
+	if err != nil {
+		return nil, detected, err
+	}
+
+	return decoded, detected, nil
+}
+
+// readInput reads the whole input at once when it is a file, whose size is known, rather than in growing chunks
+func readInput(input io.Reader) ([]byte, error) {
+	file, ok := input.(fs.File)
+
+	if !ok {
+		return io.ReadAll(input)
+	}
+
+	info, err := file.Stat()
+
+	if err != nil || info.Size() <= 0 {
+		return io.ReadAll(input)
+	}
+
+	// one more byte than the size tells whether the file grew since, in which case the rest is read as well
+	content := make([]byte, info.Size()+1)
+	read, err := io.ReadFull(input, content)
+
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return content[:read], nil
+	case nil:
+		rest, err := io.ReadAll(input)
+
+		return append(content, rest...), err
+	}
+
+	return nil, err
+}
+
+// encode encodes `content`, in UTF-8, back into the encoding it was read in, BOM included
+func (t textEncoding) encode(content []byte) ([]byte, error) {
+	encoded := content
+
+	if !t.isUTF8() {
+		var err error
+
+		encoded, err = t.encoding.NewEncoder().Bytes(content)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(bytes.Clone(t.bom), encoded...), nil
+}
@@ -0,0 +1,100 @@
+package fds
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncoding_Set(t *testing.T) {
+	var encoding Encoding
+
+	if err := encoding.Set("latin1"); err != nil || !encoding.IsSet() {
+		t.Errorf("Encoding.Set(%q) returned error %v", "latin1", err)
+	}
+
+	if err := encoding.Set("klingon"); err == nil {
+		t.Errorf("Encoding.Set() expects error for unknown encoding, none returned")
+	}
+}
+
+func TestDecodeInput(t *testing.T) {
+	var latin1 Encoding
+	latin1.Set("ISO-8859-1")
+
+	var tests = []struct {
+		name     string
+		input    []byte
+		fallback Encoding
+		want     string
+		bom      []byte
+	}{
+		{
+			name:  "UTF-8 without BOM",
+			input: []byte("mamãe"),
+			want:  "mamãe",
+		},
+		{
+			name:  "UTF-8 with BOM",
+			input: []byte("\xEF\xBB\xBFmamãe"),
+			want:  "mamãe",
+			bom:   []byte{0xEF, 0xBB, 0xBF},
+		},
+		{
+			name:  "UTF-16LE with BOM",
+			input: []byte{0xFF, 0xFE, 'm', 0, 0xE3, 0, '\n', 0},
+			want:  "mã\n",
+			bom:   []byte{0xFF, 0xFE},
+		},
+		{
+			name:  "UTF-16BE with BOM",
+			input: []byte{0xFE, 0xFF, 0, 'm', 0, 0xE3},
+			want:  "mã",
+			bom:   []byte{0xFE, 0xFF},
+		},
+		{
+			name:     "Latin-1 supplied as fallback",
+			input:    []byte{'m', 0xE3, 'e'},
+			fallback: latin1,
+			want:     "mãe",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, encoding, err := decodeInput(bytes.NewReader(tc.input), tc.fallback)
+
+			if err != nil {
+				t.Fatalf("decodeInput() returned unexpected error %s", err)
+			}
+
+			if string(result) != tc.want {
+				t.Errorf("decodeInput() = %q, want %q", result, tc.want)
+			}
+
+			if !bytes.Equal(encoding.bom, tc.bom) {
+				t.Errorf("decodeInput() detected BOM %v, want %v", encoding.bom, tc.bom)
+			}
+
+			encoded, err := encoding.encode(result)
+
+			if err != nil {
+				t.Fatalf("textEncoding.encode() returned unexpected error %s", err)
+			}
+
+			if !bytes.Equal(encoded, tc.input) {
+				t.Errorf("textEncoding.encode() = %v, want the original input %v", encoded, tc.input)
+			}
+		})
+	}
+}
+
+func TestTextEncoding_EncodeUnrepresentableCharacter(t *testing.T) {
+	var latin1 Encoding
+	latin1.Set("ISO-8859-1")
+
+	_, encoding, _ := decodeInput(bytes.NewReader([]byte("foo")), latin1)
+
+	if _, err := encoding.encode([]byte("€")); err == nil {
+		t.Errorf("textEncoding.encode() expects error for a character out of ISO-8859-1, none returned")
+	}
+}
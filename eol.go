@@ -0,0 +1,178 @@
+package fds
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type LineEnding string
+
+const (
+	LF   LineEnding = "\n"
+	CRLF LineEnding = "\r\n"
+	CR   LineEnding = "\r"
+)
+
+func (e *LineEnding) String() string {
+	switch *e {
+	case LF:
+		return "lf"
+	case CRLF:
+		return "crlf"
+	case CR:
+		return "cr"
+	}
+
+	return ""
+}
+
+func (e *LineEnding) Type() string {
+	return "lf|crlf|cr"
+}
+
+func (e *LineEnding) Set(value string) error {
+	switch strings.ToLower(value) {
+	case "lf":
+		*e = LF
+	case "crlf":
+		*e = CRLF
+	case "cr":
+		*e = CR
+	default:
+		return fmt.Errorf("%q is not a valid line ending. Use lf, crlf or cr", value)
+	}
+
+	return nil
+}
+
+// UnmarshalText lets line endings be read from configuration files, where an empty one keeps the ones of each line
+func (e *LineEnding) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*e = ""
+
+		return nil
+	}
+
+	return e.Set(string(text))
+}
+
+/**
+ * lineReader reads lines ending in LF, CRLF or CR, handing out their content and line ending separately,
+ * so that patterns anchored with `$` match regardless of the line ending of the file
+ */
+type lineReader struct {
+	reader *bufio.Reader
+}
+
+func newLineReader(input io.Reader) *lineReader {
+	return &lineReader{reader: bufio.NewReader(input)}
+}
+
+/**
+ * ReadLine returns the next line and its line ending. The last line of the input is returned along with io.EOF,
+ * having no line ending. When the input ends with a line ending, there is no last line and io.EOF comes alone
+ */
+func (l *lineReader) ReadLine() (string, LineEnding, error) {
+	var line []byte
+
+	for {
+		// the bytes buffered are looked through at once, rather than read one by one
+		if l.reader.Buffered() == 0 {
+			if _, err := l.reader.Peek(1); err != nil {
+				return string(line), "", err
+			}
+		}
+
+		buffered, _ := l.reader.Peek(l.reader.Buffered())
+		end := bytes.IndexByte(buffered, '\n')
+
+		if end < 0 {
+			end = len(buffered)
+		}
+
+		// a CR before the LF, if any, ends the line first, either as a CRLF or alone
+		if cr := bytes.IndexByte(buffered[:end], '\r'); cr >= 0 {
+			end = cr
+		}
+
+		if end == len(buffered) {
+			line = append(line, buffered...)
+			l.reader.Discard(end)
+
+			continue
+		}
+
+		ending := buffered[end]
+		text := string(buffered[:end])
+
+		if line != nil {
+			text = string(append(line, buffered[:end]...))
+		}
+
+		l.reader.Discard(end + 1)
+
+		if ending == '\n' {
+			return text, LF, nil
+		}
+
+		if next, err := l.reader.Peek(1); err == nil && next[0] == '\n' {
+			l.reader.Discard(1)
+
+			return text, CRLF, nil
+		}
+
+		return text, CR, nil
+	}
+}
+
+// lineWriter writes lines back with their original line ending, unless a line ending to normalise to is supplied
+type lineWriter struct {
+	writer     *bufio.Writer
+	lineEnding LineEnding
+
+	// lastEnding is used to separate lines inserted around the last line, which has no line ending of its own
+	lastEnding LineEnding
+
+	// normalised tells whether any line ending was changed while writing
+	normalised bool
+}
+
+func newLineWriter(output io.Writer, lineEnding LineEnding) *lineWriter {
+	return &lineWriter{writer: bufio.NewWriter(output), lineEnding: lineEnding, lastEnding: LF}
+}
+
+/**
+ * WriteLines writes what replaced a single line of the input: no lines when it was deleted,
+ * more than one when lines were inserted around it
+ */
+func (w *lineWriter) WriteLines(lines []string, ending LineEnding) error {
+	if ending != "" && w.lineEnding != "" {
+		w.normalised = w.normalised || ending != w.lineEnding
+		ending = w.lineEnding
+	}
+
+	if ending != "" {
+		w.lastEnding = ending
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	separator := w.lastEnding
+
+	if w.lineEnding != "" {
+		separator = w.lineEnding
+	}
+
+	_, err := w.writer.WriteString(strings.Join(lines, string(separator)) + string(ending))
+
+	return err
+}
+
+func (w *lineWriter) Flush() error {
+	return w.writer.Flush()
+}
@@ -0,0 +1,153 @@
+package fds
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineReader_ReadLine(t *testing.T) {
+	type line struct {
+		content string
+		ending  LineEnding
+	}
+
+	var tests = []struct {
+		name  string
+		input string
+		want  []line
+	}{
+		{
+			name:  "lf",
+			input: "foo\nbar\n",
+			want:  []line{{"foo", LF}, {"bar", LF}},
+		},
+		{
+			name:  "crlf without trailing line ending",
+			input: "foo\r\nbar",
+			want:  []line{{"foo", CRLF}, {"bar", ""}},
+		},
+		{
+			name:  "cr",
+			input: "foo\rbar\r",
+			want:  []line{{"foo", CR}, {"bar", CR}},
+		},
+		{
+			name:  "lines longer than the buffer",
+			input: strings.Repeat("a", 5000) + "\r" + strings.Repeat("b", 5000) + "\r\n" + strings.Repeat("c", 5000),
+			want:  []line{{strings.Repeat("a", 5000), CR}, {strings.Repeat("b", 5000), CRLF}, {strings.Repeat("c", 5000), ""}},
+		},
+		{
+			name:  "mixed line endings and empty lines",
+			input: "foo\r\n\nbar\r\r\n",
+			want:  []line{{"foo", CRLF}, {"", LF}, {"bar", CR}, {"", CRLF}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var result []line
+
+			reader := newLineReader(strings.NewReader(tc.input))
+
+			for {
+				content, ending, err := reader.ReadLine()
+
+				if err == io.EOF && content == "" {
+					break
+				}
+
+				result = append(result, line{content, ending})
+
+				if err == io.EOF {
+					break
+				}
+			}
+
+			if len(result) != len(tc.want) {
+				t.Fatalf("lineReader.ReadLine() read %+v, want %+v", result, tc.want)
+			}
+
+			for i := range result {
+				if result[i] != tc.want[i] {
+					t.Errorf("lineReader.ReadLine() read %+v, want %+v", result, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLineReader_ReadLine(b *testing.B) {
+	content := strings.Repeat("lorem ipsum dolor sit amet, consectetur adipiscing elit\r\n", 20000)
+
+	b.SetBytes(int64(len(content)))
+
+	for b.Loop() {
+		reader := newLineReader(strings.NewReader(content))
+
+		for {
+			if _, _, err := reader.ReadLine(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func TestLineWriter_WriteLines(t *testing.T) {
+	var tests = []struct {
+		name       string
+		lineEnding LineEnding
+		want       string
+		normalised bool
+	}{
+		{
+			name: "keeps original line endings",
+			want: "foo\r\nbar\ninserted\nbaz",
+		},
+		{
+			name:       "normalises line endings",
+			lineEnding: LF,
+			want:       "foo\nbar\ninserted\nbaz",
+			normalised: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			writer := newLineWriter(&buffer, tc.lineEnding)
+			writer.WriteLines([]string{"foo"}, CRLF)
+			writer.WriteLines(nil, CR)
+			writer.WriteLines([]string{"bar", "inserted"}, LF)
+			writer.WriteLines([]string{"baz"}, "")
+			writer.Flush()
+
+			if result := buffer.String(); result != tc.want {
+				t.Errorf("lineWriter.WriteLines() wrote %q, want %q", result, tc.want)
+			}
+
+			if writer.normalised != tc.normalised {
+				t.Errorf("lineWriter.normalised = %t, want %t", writer.normalised, tc.normalised)
+			}
+		})
+	}
+}
+
+func TestLineEnding_Set(t *testing.T) {
+	var lineEnding LineEnding
+
+	if err := lineEnding.Set("CRLF"); err != nil || lineEnding != CRLF {
+		t.Errorf("LineEnding.Set(%q) = %q, want %q", "CRLF", lineEnding, CRLF)
+	}
+
+	// lone CRs are read as line endings, so they can be normalised to as well
+	if err := lineEnding.Set("cr"); err != nil || lineEnding != CR || lineEnding.String() != "cr" {
+		t.Errorf("LineEnding.Set(%q) = %q, want %q", "cr", lineEnding, CR)
+	}
+
+	if err := lineEnding.Set("crlf\n"); err == nil {
+		t.Errorf("LineEnding.Set() expects error for invalid line ending, none returned")
+	}
+}
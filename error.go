@@ -1,9 +1,66 @@
 package fds
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/**
+ * Exit statuses of fds. Errors exit with their own Code instead, all of them listed in the README
+ */
+const (
+	// ExitSuccess means something was replaced, or there was nothing to replace in, as when printing help
+	ExitSuccess = 0
+	// ExitNoMatch means the search pattern matched nothing, or no replacement was confirmed
+	ExitNoMatch = 1
+	// ExitError is used for errors that do not carry a Code
+	ExitError = 2
+	// ExitPartialFailure means some of the files could not be replaced in, while the others could
+	ExitPartialFailure = 3
+	// ExitTotalFailure means none of the files could be replaced in
+	ExitTotalFailure = 4
+	// ExitInterrupted means fds was interrupted, by Ctrl-C for instance, before replacing in all files
+	ExitInterrupted = 130
+)
+
+/**
+ * Sentinel errors, one for each kind of error returned by fds. Use them along with errors.Is:
+ *
+ *	if errors.Is(err, fds.ErrFileRead) && errors.Is(err, fs.ErrPermission) { ... }
+ */
+var (
+	ErrInvalidRegExp          = errors.New("invalid regular expression")
+	ErrInvalidArguments       = errors.New("invalid arguments")
+	ErrFileNotFound           = errors.New("file not found")
+	ErrLiteralInsensitive     = errors.New("literal used along with insensitive")
+	ErrConfirmNotOnFile       = errors.New("confirm used without files nor terminal")
+	ErrSelectionNotOnFile     = errors.New("line selection used without files")
+	ErrLineOperationNotOnFile = errors.New("line operations used without files")
+	ErrFileRead               = errors.New("file read failed")
+	ErrFileWrite              = errors.New("file write failed")
+	ErrTempFileWrite          = errors.New("temporary file write failed")
+	ErrFileEncode             = errors.New("file encoding failed")
+	ErrStdinRead              = errors.New("stdin read failed")
+	ErrRenameFile             = errors.New("temporary file rename failed")
+	ErrAbortedOperation       = errors.New("aborted operation")
+	ErrDirectoryRead          = errors.New("directory read failed")
+	ErrPartialFailure         = errors.New("partial failure")
+	ErrTotalFailure           = errors.New("total failure")
+	ErrConfirmWithoutCallback = errors.New("confirm used without a confirm callback")
+	ErrInterrupted            = errors.New("interrupted")
+	ErrReviewNotOnFile        = errors.New("review used without files or terminal")
+	ErrAnswersRead            = errors.New("answers read failed")
+	ErrMissingAnswer          = errors.New("missing answer")
+	ErrConfigFile             = errors.New("configuration file read failed")
+	ErrInvalidOption          = errors.New("invalid option")
+)
 
 type InputError struct {
 	message string
+	kind    error
+	err     error
+	Path    string
 	Code    int
 }
 
@@ -11,8 +68,19 @@ func (e InputError) Error() string {
 	return fmt.Sprintf("%s\n\n%s", Usage, e.message)
 }
 
+func (e InputError) Unwrap() []error {
+	return unwrap(e.kind, e.err)
+}
+
+/**
+ * Error is returned when something goes wrong while replacing. It wraps the sentinel error of its kind and,
+ * when there is one, the error that caused it, such as the fs.PathError returned by the os package
+ */
 type Error struct {
 	message string
+	kind    error
+	err     error
+	Path    string
 	Code    int
 }
 
@@ -20,68 +88,169 @@ func (e Error) Error() string {
 	return e.message
 }
 
+func (e Error) Unwrap() []error {
+	return unwrap(e.kind, e.err)
+}
+
+func unwrap(kind, err error) []error {
+	if err == nil {
+		return []error{kind}
+	}
+
+	return []error{kind, err}
+}
+
 func NewInvalidRegExpError() InputError {
-	return InputError{message: "subject is not a valid Regular Expression", Code: 42}
+	return InputError{message: "subject is not a valid Regular Expression", kind: ErrInvalidRegExp, Code: 42}
 }
 
 func NewInvalidArgumentsError() InputError {
-	return InputError{message: "Invalid arguments", Code: 43}
+	return InputError{message: "Invalid arguments", kind: ErrInvalidArguments, Code: 43}
 }
 
-func NewInvalidArgumentsErrorFileNotFound(filePath string) InputError {
-	return InputError{message: fmt.Sprintf("File '%s' could not be found", filePath), Code: 44}
+func NewInvalidArgumentsErrorFileNotFound(filePath string, err error) InputError {
+	return InputError{message: fmt.Sprintf("File '%s' could not be found", filePath), kind: ErrFileNotFound, err: err, Path: filePath, Code: 44}
 }
 
 func NewLiteralInsensitiveError() InputError {
-	return InputError{message: "[-l, --literal] cannot be used along with [ -i, --insensitive ]", Code: 45}
+	return InputError{message: "[-l, --literal] cannot be used along with [ -i, --insensitive ]", kind: ErrLiteralInsensitive, Code: 45}
+}
+
+func NewInvalidOptionError(option, message string) InputError {
+	return InputError{message: fmt.Sprintf("[--%s] %s", option, message), kind: ErrInvalidOption, Code: 63}
 }
 
 func NewConfirmNotOnFileError() InputError {
-	return InputError{message: "[-c, --confirm] can only be used when files are supplied, not with STDIN nor positional arguments", Code: 45}
+	return InputError{message: "[-c, --confirm] can only be used along with STDIN when there is a terminal to confirm on", kind: ErrConfirmNotOnFile, Code: 56}
 }
 
-func NewFileReadError(file string) Error {
-	return Error{message: fmt.Sprintf("Failed to read file %q. Do you have permission to read it?", file), Code: 46}
+func NewSelectionNotOnFileError() InputError {
+	return InputError{message: "[--lines, --after-pattern, --before-pattern, --where] can only be used when files are supplied, not with STDIN nor positional arguments", kind: ErrSelectionNotOnFile, Code: 53}
 }
 
-func NewFileWriteError(file string) Error {
-	return Error{message: fmt.Sprintf("Failed to write file %q. Do you have permission to write in directory?", file), Code: 47}
+func NewLineOperationNotOnFileError() InputError {
+	return InputError{message: "[--delete-line, --insert-before, --insert-after] can only be used when files are supplied, not with STDIN nor positional arguments", kind: ErrLineOperationNotOnFile, Code: 54}
 }
 
-func NewTempFileWriteError(dir string) Error {
-	return Error{message: fmt.Sprintf("Failed to write temporary file. Do you have permission to write in directory %q?", dir), Code: 48}
+func NewReviewNotOnFileError() InputError {
+	return InputError{message: "[--tui] can only be used on a terminal when files are supplied, not with STDIN nor positional arguments", kind: ErrReviewNotOnFile, Code: 59}
 }
 
-func NewStdinReadError() Error {
-	return Error{message: "Failed to read from Stdin", Code: 49}
+func NewAnswersReadError(answers string, err error) InputError {
+	return InputError{message: fmt.Sprintf("[--answers] %q is neither a file of recorded answers nor a sequence of answers such as \"yyn a\": %s", answers, err), kind: ErrAnswersRead, err: err, Path: answers, Code: 60}
 }
 
-func NewRenameFileError(file string) Error {
-	return Error{message: fmt.Sprintf("Failed to rename temp file into original file %q", file), Code: 50}
+func NewMissingAnswerError(file, prompted string) Error {
+	return Error{message: fmt.Sprintf("No answer left for %s of file %q", prompted, file), kind: ErrMissingAnswer, Path: file, Code: 61}
 }
 
-func NewAbortedOperationError() Error {
-	return Error{message: "Aborted operation", Code: 51}
+func NewConfirmWithoutCallbackError() Error {
+	return Error{message: "Options.Confirm must be set when Config.Confirm is", kind: ErrConfirmWithoutCallback, Code: 58}
 }
 
-func NewDirectoryReadError(dir string) Error {
-	return Error{message: fmt.Sprintf("Failed to read directory %q. Do you have permission to read it?", dir), Code: 52}
+func NewConfigFileError(path string, err error) Error {
+	return Error{message: fmt.Sprintf("Configuration file %q could not be read: %s", path, err), kind: ErrConfigFile, err: err, Path: path, Code: 62}
 }
 
-type ConfirmError struct {
-	input   rune
-	message string
-	Code    int
+func NewFileReadError(file string, err error) Error {
+	return Error{message: fmt.Sprintf("Failed to read file %q. Do you have permission to read it?", file), kind: ErrFileRead, err: err, Path: file, Code: 46}
+}
+
+func NewFileWriteError(file string, err error) Error {
+	return Error{message: fmt.Sprintf("Failed to write file %q. Do you have permission to write in directory?", file), kind: ErrFileWrite, err: err, Path: file, Code: 47}
+}
+
+func NewTempFileWriteError(dir string, err error) Error {
+	return Error{message: fmt.Sprintf("Failed to write temporary file. Do you have permission to write in directory %q?", dir), kind: ErrTempFileWrite, err: err, Path: dir, Code: 48}
+}
+
+func NewFileEncodeError(file, encoding string, err error) Error {
+	return Error{message: fmt.Sprintf("Failed to write file %q in its original encoding %s. Does the replacement contain characters it cannot represent?", file, encoding), kind: ErrFileEncode, err: err, Path: file, Code: 55}
+}
+
+func NewStdinReadError(err error) Error {
+	return Error{message: "Failed to read from Stdin", kind: ErrStdinRead, err: err, Code: 49}
+}
+
+func NewRenameFileError(file string, err error) Error {
+	return Error{message: fmt.Sprintf("Failed to rename temp file into original file %q", file), kind: ErrRenameFile, err: err, Path: file, Code: 50}
 }
 
-func (e ConfirmError) Error() string {
-	return fmt.Sprintf("%s: %c", e.message, e.input)
+func NewAbortedOperationError(file string) Error {
+	return Error{message: fmt.Sprintf("Aborted operation, file %q was not overwritten", file), kind: ErrAbortedOperation, Path: file, Code: 51}
 }
 
-func NewInvalidConfirmInputError(input rune) ConfirmError {
-	return ConfirmError{
-		message: "Invalid input",
-		input:   input,
-		Code:    46,
+func NewDirectoryReadError(dir string, err error) Error {
+	return Error{message: fmt.Sprintf("Failed to read directory %q. Do you have permission to read it?", dir), kind: ErrDirectoryRead, err: err, Path: dir, Code: 52}
+}
+
+// NewInterruptedError wraps the error of the cancelled context, such as context.Canceled
+func NewInterruptedError(err error) Error {
+	return Error{message: "Interrupted", kind: ErrInterrupted, err: err, Code: ExitInterrupted}
+}
+
+/**
+ * FilesError aggregates the errors of every file that could not be replaced in, out of `Total` files. It wraps
+ * ErrTotalFailure when all of them failed, ErrPartialFailure otherwise. errors.Is and errors.As look into each one
+ * of `Errors`
+ */
+type FilesError struct {
+	Errors []error
+	Total  int
+	Code   int
+
+	kind error
+}
+
+func (e FilesError) Error() string {
+	messages := []string{fmt.Sprintf("Failed to replace in %d out of %d files", len(e.Errors), e.Total)}
+
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func (e FilesError) Unwrap() []error {
+	return append([]error{e.kind}, e.Errors...)
+}
+
+// NewFilesError aggregates the errors of the files that failed, telling a total failure from a partial one
+func NewFilesError(errs []error, total int) FilesError {
+	if len(errs) == total {
+		return NewTotalFailureError(errs)
+	}
+
+	return NewPartialFailureError(errs, total)
+}
+
+func NewPartialFailureError(errs []error, total int) FilesError {
+	return FilesError{Errors: errs, Total: total, Code: ExitPartialFailure, kind: ErrPartialFailure}
+}
+
+func NewTotalFailureError(errs []error) FilesError {
+	return FilesError{Errors: errs, Total: len(errs), Code: ExitTotalFailure, kind: ErrTotalFailure}
+}
+
+/**
+ * ExitCode tells the exit status for `err`: the Code of the outermost error from fds, ExitError otherwise
+ */
+func ExitCode(err error) int {
+	var filesErr FilesError
+	var inputErr InputError
+	var thrownErr Error
+
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.As(err, &filesErr):
+		return filesErr.Code
+	case errors.As(err, &inputErr):
+		return inputErr.Code
+	case errors.As(err, &thrownErr):
+		return thrownErr.Code
 	}
+
+	return ExitError
 }
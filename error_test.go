@@ -1,6 +1,10 @@
 package fds
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"regexp"
 	"strings"
 	"testing"
@@ -41,7 +45,7 @@ func TestNewInvalidArgumentsError(t *testing.T) {
 }
 
 func TestNewInvalidArgumentsErrorFileNotFound(t *testing.T) {
-	err := NewInvalidArgumentsErrorFileNotFound("foo")
+	err := NewInvalidArgumentsErrorFileNotFound("foo", nil)
 	want := regexp.MustCompile("File 'foo' could not be found")
 
 	if !want.MatchString(err.Error()) {
@@ -58,26 +62,26 @@ func TestNewLiteralInsensitiveError(t *testing.T) {
 	}
 }
 
-func TestNewConfirmNotOnFileError(t *testing.T) {
-	err := NewConfirmNotOnFileError()
-	want := regexp.MustCompile("can only be used when files are supplied")
+func TestNewReviewNotOnFileError(t *testing.T) {
+	err := NewReviewNotOnFileError()
+	want := regexp.MustCompile("can only be used on a terminal when files are supplied")
 
 	if !want.MatchString(err.Error()) {
-		t.Errorf(`NewConfirmNotOnFileError().Error() = %q, does not match RegExp %q`, err.Error(), want)
+		t.Errorf(`NewReviewNotOnFileError().Error() = %q, does not match RegExp %q`, err.Error(), want)
 	}
 }
 
-func TestNewInvalidConfirmInputError(t *testing.T) {
-	err := NewInvalidConfirmInputError('t')
-	want := regexp.MustCompile("Invalid input: t")
+func TestNewConfirmNotOnFileError(t *testing.T) {
+	err := NewConfirmNotOnFileError()
+	want := regexp.MustCompile("can only be used along with STDIN when there is a terminal")
 
 	if !want.MatchString(err.Error()) {
-		t.Errorf(`NewInvalidConfirmInputError().Error() = %q, does not match RegExp %q`, err.Error(), want)
+		t.Errorf(`NewConfirmNotOnFileError().Error() = %q, does not match RegExp %q`, err.Error(), want)
 	}
 }
 
 func TestNewFileReadError(t *testing.T) {
-	err := NewFileReadError("/file/path")
+	err := NewFileReadError("/file/path", nil)
 	want := regexp.MustCompile(`Failed to read file "/file/path"`)
 	code := 46
 
@@ -91,7 +95,7 @@ func TestNewFileReadError(t *testing.T) {
 }
 
 func TestNewFileWriteError(t *testing.T) {
-	err := NewFileWriteError("/file/path")
+	err := NewFileWriteError("/file/path", nil)
 	want := regexp.MustCompile(`Failed to write file "/file/path"`)
 	code := 47
 
@@ -105,7 +109,7 @@ func TestNewFileWriteError(t *testing.T) {
 }
 
 func TestNewTempFileWriteError(t *testing.T) {
-	err := NewTempFileWriteError("/file/path")
+	err := NewTempFileWriteError("/file/path", nil)
 	want := regexp.MustCompile(`Failed to write temporary file`)
 	code := 48
 
@@ -119,21 +123,21 @@ func TestNewTempFileWriteError(t *testing.T) {
 }
 
 func TestNewStdinReadError(t *testing.T) {
-	err := NewStdinReadError()
+	err := NewStdinReadError(nil)
 	want := regexp.MustCompile(`Failed to read from Stdin`)
 	code := 49
 
 	if !want.MatchString(err.Error()) {
-		t.Errorf(`NewStdinReadError().Error() = %q, does not match RegExp %q`, err.Error(), want)
+		t.Errorf(`NewStdinReadError(nil).Error() = %q, does not match RegExp %q`, err.Error(), want)
 	}
 
 	if err.Code != code {
-		t.Errorf(`NewStdinReadError().Code = %d, want %d`, err.Code, code)
+		t.Errorf(`NewStdinReadError(nil).Code = %d, want %d`, err.Code, code)
 	}
 }
 
 func TestNewRenameFileError(t *testing.T) {
-	err := NewRenameFileError("/file/path")
+	err := NewRenameFileError("/file/path", nil)
 	want := regexp.MustCompile(`Failed to rename temp file into original file \"/file/path\"`)
 	code := 50
 
@@ -147,7 +151,7 @@ func TestNewRenameFileError(t *testing.T) {
 }
 
 func TestNewAbortedOperationError(t *testing.T) {
-	err := NewAbortedOperationError()
+	err := NewAbortedOperationError("file.txt")
 	want := regexp.MustCompile(`Aborted operation`)
 	code := 51
 
@@ -161,7 +165,7 @@ func TestNewAbortedOperationError(t *testing.T) {
 }
 
 func TestNewDirectoryReadError(t *testing.T) {
-	err := NewDirectoryReadError("/file/path")
+	err := NewDirectoryReadError("/file/path", nil)
 	want := regexp.MustCompile(`Failed to read directory "/file/path"`)
 	code := 52
 
@@ -173,3 +177,151 @@ func TestNewDirectoryReadError(t *testing.T) {
 		t.Errorf(`NewDirectoryReadError().Code = %d, want %d`, err.Code, code)
 	}
 }
+
+func TestNewPartialFailureError(t *testing.T) {
+	err := NewPartialFailureError([]error{NewFileReadError("/file/a", nil), NewFileReadError("/file/b", nil)}, 5)
+	want := regexp.MustCompile(`Failed to replace in 2 out of 5 files\nFailed to read file "/file/a".*\nFailed to read file "/file/b"`)
+
+	if !want.MatchString(err.Error()) {
+		t.Errorf(`NewPartialFailureError().Error() = %q, does not match RegExp %q`, err.Error(), want)
+	}
+
+	if err.Code != ExitPartialFailure {
+		t.Errorf(`NewPartialFailureError().Code = %d, want %d`, err.Code, ExitPartialFailure)
+	}
+}
+
+func TestNewFilesError(t *testing.T) {
+	errs := []error{NewFileReadError("/file/a", nil), NewFileReadError("/file/b", nil)}
+
+	tests := []struct {
+		name      string
+		total     int
+		wantKind  error
+		otherKind error
+		wantCode  int
+	}{
+		{name: "Some files failed", total: 3, wantKind: ErrPartialFailure, otherKind: ErrTotalFailure, wantCode: ExitPartialFailure},
+		{name: "All files failed", total: 2, wantKind: ErrTotalFailure, otherKind: ErrPartialFailure, wantCode: ExitTotalFailure},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewFilesError(errs, tc.total)
+
+			if !errors.Is(err, tc.wantKind) || errors.Is(err, tc.otherKind) || ExitCode(err) != tc.wantCode {
+				t.Errorf("NewFilesError() = %v with code %d, want %q only with code %d", err, ExitCode(err), tc.wantKind, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestErrorCodesAreUnique(t *testing.T) {
+	codes := map[string]int{
+		"InvalidRegExp":          NewInvalidRegExpError().Code,
+		"InvalidArguments":       NewInvalidArgumentsError().Code,
+		"FileNotFound":           NewInvalidArgumentsErrorFileNotFound("", nil).Code,
+		"LiteralInsensitive":     NewLiteralInsensitiveError().Code,
+		"ConfirmNotOnFile":       NewConfirmNotOnFileError().Code,
+		"SelectionNotOnFile":     NewSelectionNotOnFileError().Code,
+		"LineOperationNotOnFile": NewLineOperationNotOnFileError().Code,
+		"FileRead":               NewFileReadError("", nil).Code,
+		"FileWrite":              NewFileWriteError("", nil).Code,
+		"TempFileWrite":          NewTempFileWriteError("", nil).Code,
+		"FileEncode":             NewFileEncodeError("", "", nil).Code,
+		"StdinRead":              NewStdinReadError(nil).Code,
+		"RenameFile":             NewRenameFileError("", nil).Code,
+		"AbortedOperation":       NewAbortedOperationError("").Code,
+		"DirectoryRead":          NewDirectoryReadError("", nil).Code,
+		"PartialFailure":         NewPartialFailureError(nil, 2).Code,
+		"TotalFailure":           NewTotalFailureError(nil).Code,
+		"ConfirmWithoutCallback": NewConfirmWithoutCallbackError().Code,
+		"Interrupted":            NewInterruptedError(nil).Code,
+		"ReviewNotOnFile":        NewReviewNotOnFileError().Code,
+		"AnswersRead":            NewAnswersReadError("", nil).Code,
+		"MissingAnswer":          NewMissingAnswerError("", "").Code,
+		"ConfigFile":             NewConfigFileError("", nil).Code,
+		"InvalidOption":          NewInvalidOptionError("", "").Code,
+	}
+
+	seen := map[int]string{ExitSuccess: "ExitSuccess", ExitNoMatch: "ExitNoMatch", ExitError: "ExitError"}
+
+	for name, code := range codes {
+		if other, ok := seen[code]; ok && other != name {
+			t.Errorf("Code %d is used by both %s and %s", code, name, other)
+		}
+
+		seen[code] = name
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	var tests = []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "Error", err: NewFileReadError("", nil), want: NewFileReadError("", nil).Code},
+		{name: "InputError", err: NewInvalidArgumentsError(), want: NewInvalidArgumentsError().Code},
+		{name: "FilesError", err: NewPartialFailureError([]error{NewFileReadError("", nil)}, 2), want: ExitPartialFailure},
+		{name: "wrapped Error", err: fmt.Errorf("wrapped: %w", NewFileReadError("", nil)), want: NewFileReadError("", nil).Code},
+		{name: "other errors", err: io.EOF, want: ExitError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := ExitCode(tc.err); result != tc.want {
+				t.Errorf("ExitCode(%q) = %d, want %d", tc.err, result, tc.want)
+			}
+		})
+	}
+}
+
+func TestErrorWrapsKindAndCause(t *testing.T) {
+	cause := &fs.PathError{Op: "open", Path: "/file/path", Err: fs.ErrPermission}
+	err := NewFileReadError("/file/path", cause)
+
+	if !errors.Is(err, ErrFileRead) {
+		t.Errorf("errors.Is(NewFileReadError(), ErrFileRead) = false, want true")
+	}
+
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("errors.Is(NewFileReadError(), fs.ErrPermission) = false, want true")
+	}
+
+	if errors.Is(err, ErrFileWrite) {
+		t.Errorf("errors.Is(NewFileReadError(), ErrFileWrite) = true, want false")
+	}
+
+	var pathErr *fs.PathError
+
+	if !errors.As(err, &pathErr) || pathErr != cause {
+		t.Errorf("errors.As(NewFileReadError(), *fs.PathError) did not find the cause")
+	}
+
+	if err.Path != "/file/path" {
+		t.Errorf("NewFileReadError().Path = %q, want %q", err.Path, "/file/path")
+	}
+}
+
+func TestFilesErrorWrapsEachError(t *testing.T) {
+	err := error(NewPartialFailureError([]error{NewFileReadError("/file/a", fs.ErrPermission), NewRenameFileError("/file/b", nil)}, 3))
+
+	for _, target := range []error{ErrPartialFailure, ErrFileRead, ErrRenameFile, fs.ErrPermission} {
+		if !errors.Is(err, target) {
+			t.Errorf("errors.Is(FilesError, %q) = false, want true", target)
+		}
+	}
+
+	var filesErr FilesError
+
+	if !errors.As(err, &filesErr) || len(filesErr.Errors) != 2 || filesErr.Total != 3 {
+		t.Errorf("errors.As(FilesError) = %+v, want 2 errors out of 3 files", filesErr)
+	}
+
+	var thrownErr Error
+
+	if !errors.As(filesErr.Errors[1], &thrownErr) || thrownErr.Path != "/file/b" {
+		t.Errorf("FilesError.Errors[1] = %+v, want Error with path /file/b", filesErr.Errors[1])
+	}
+}
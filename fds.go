@@ -1,202 +1,359 @@
 package fds
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
-	"log"
-	"os"
+	"maps"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
 )
 
-func ReplaceInFile(replacer FileReplacer, stdin io.Reader, stdout io.Writer, confirmAnswer *ConfirmAnswer) error {
-	var err error
-
+/**
+ * ReplaceInFile replaces in a single file, telling whether the file was changed. The file is either replaced in
+ * completely or left untouched: once `ctx` is cancelled, or on any failure, the temporary file is discarded
+ * instead of renamed
+ */
+func ReplaceInFile(ctx context.Context, replacer FileReplacer, stdin io.Reader, stdout io.Writer, confirmAnswer *ConfirmAnswer) (replaced bool, err error) {
 	file := replacer.inputFilePath
 	search := replacer.search
 	replace := replacer.replace
 
-	inputStat, _ := os.Stat(file)
-	originalModTime := inputStat.ModTime()
+	fileSystem := replacer.config.fileSystem()
+	inputStat, err := fileSystem.Stat(file)
 
-	if replacer.HasFlag("verbose") {
-		log.Printf("Replacing %s for %s in file %s", search, replace, file)
+	if err != nil {
+		return false, NewFileReadError(file, err)
 	}
 
-	tmpFile, err := replacer.Replace(stdin, stdout, confirmAnswer)
+	originalModTime := inputStat.ModTime()
+
+	replacer.config.logf("Replacing %s in file %s, replacements being expanded from %q", search, file, replace)
+
+	tmpFile, err := replacer.Replace(ctx, stdin, stdout, confirmAnswer)
 
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if tmpFile == nil {
-		if replacer.HasFlag("verbose") {
-			log.Printf("Nothing replaced in file %s", file)
-		}
+		replacer.config.logf("Nothing replaced in file %s", file)
 
-		return nil
+		return false, nil
 	}
 
-	inputStat, _ = os.Stat(file)
-	inputFileChangedSinceRead := inputStat.ModTime().After(originalModTime)
-	renameFile := true
+	inputStat, err = fileSystem.Stat(file)
+
+	if err != nil {
+		discardTempFile(fileSystem, tmpFile)
 
-	if replacer.HasFlag("verbose") {
-		log.Printf("Replace in temp file completed")
-		log.Printf("Original timestamp of file %s: %s", file, originalModTime)
+		return false, NewFileReadError(file, err)
 	}
 
+	inputFileChangedSinceRead := inputStat.ModTime().After(originalModTime)
+
+	replacer.config.logf("Replace in temp file completed")
+	replacer.config.logf("Original timestamp of file %s: %s", file, originalModTime)
+
 	if inputFileChangedSinceRead {
-		if replacer.HasFlag("verbose") {
-			log.Printf("File %s has been modified since %s", file, originalModTime)
-		}
+		replacer.config.logf("File %s has been modified since %s", file, originalModTime)
 
+		confirm := replacer.confirmFunc(stdin, stdout)
 		confirmText := fmt.Sprintf("File %s was modified after initial read. Overwrite anyway? [y]es [n]o", file)
-		answer, _ := Confirm(stdin, confirmText, []rune{'y', 'n'})
+		answer, _ := confirm(Prompt{File: file, Text: confirmText, Valid: []rune{'y', 'n'}})
 
-		if answer == 'n' {
-			renameFile = false
+		if answer.Choice != 'y' {
+			discardTempFile(fileSystem, tmpFile)
+			replacer.config.logf("File %s will not be overwritten", file)
 
-			if replacer.HasFlag("verbose") && inputFileChangedSinceRead {
-				log.Printf("File %s will not be overwritten", file)
-
-				return NewAbortedOperationError()
-			}
+			return false, NewAbortedOperationError(file)
 		}
 	}
 
-	if renameFile {
-		if replacer.HasFlag("verbose") && inputFileChangedSinceRead {
-			log.Printf("Overwriting file %s with contents from temp file", file)
-		}
+	if err = ctx.Err(); err != nil {
+		discardTempFile(fileSystem, tmpFile)
+		replacer.config.logf("Discarded temp file %s as replacing was interrupted", tmpFile.Name())
 
-		err = os.Rename(tmpFile.Name(), file)
+		return false, NewInterruptedError(err)
+	}
 
-		if err != nil {
-			return NewRenameFileError(file)
-		}
+	if inputFileChangedSinceRead {
+		replacer.config.logf("Overwriting file %s with contents from temp file", file)
+	}
 
-		if replacer.HasFlag("verbose") {
-			log.Printf("Renamed temp file %s to %s", tmpFile.Name(), file)
-		}
+	err = fileSystem.Rename(tmpFile.Name(), file)
+
+	if err != nil {
+		discardTempFile(fileSystem, tmpFile)
+
+		return false, NewRenameFileError(file, err)
 	}
 
-	return err
+	replacer.config.logf("Renamed temp file %s to %s", tmpFile.Name(), file)
+
+	return true, nil
 }
 
-func worker(id int, args Args, wg *sync.WaitGroup, stdin io.Reader, stdout io.Writer, config Config, jobs <-chan string, errors chan<- string) {
-	defer wg.Done()
+// fileRun replaces in files on behalf of Run and ReplaceInFiles, with the same arguments for every file
+type fileRun struct {
+	args     Args
+	config   Config
+	stdin    io.Reader
+	stdout   io.Writer
+	confirm  ConfirmFunc
+	progress ProgressFunc
+
+	// progressMutex serialises the calls to progress made by the workers
+	progressMutex *sync.Mutex
+}
 
-	if config.Flags["verbose"] {
-		log.Printf("Worker %d initialized", id)
+func (f fileRun) notify(stage ProgressStage, file FileReport) {
+	if f.progress == nil {
+		return
 	}
 
-	for file := range jobs {
-		replacer := NewFileReplacer(file, args.Search, args.Replace, config)
+	f.progressMutex.Lock()
+	defer f.progressMutex.Unlock()
 
-		err := ReplaceInFile(replacer, stdin, stdout, nil)
+	f.progress(Progress{Stage: stage, File: file})
+}
 
-		if err != nil {
-			errors <- err.Error()
+func (f fileRun) replaceFile(ctx context.Context, file string, confirmAnswer *ConfirmAnswer) FileReport {
+	f.notify(FileStarted, FileReport{Path: file})
+
+	replacer := NewFileReplacer(file, f.args.Search, f.args.Replace, f.config).WithConfirm(f.confirm)
+	replaced, err := ReplaceInFile(ctx, replacer, f.stdin, f.stdout, confirmAnswer)
+	report := FileReport{Path: file, Replaced: replaced, Err: err}
+
+	if replaced {
+		report.Replacements = replacer.replacements()
+	}
+
+	return report
+}
+
+// fileJob is a file to be replaced in, numbered in the order it was found
+type fileJob struct {
+	index int
+	path  string
+}
+
+type fileResult struct {
+	index  int
+	report FileReport
+}
+
+// fileSource hands out the files to be replaced in, one by one, as they are found
+type fileSource func(ctx context.Context, found func(path string) error) error
+
+// listedFiles is the fileSource of files already known
+func listedFiles(files []string) fileSource {
+	return func(ctx context.Context, found func(path string) error) error {
+		for _, file := range files {
+			if err := found(file); err != nil {
+				return err
+			}
 		}
+
+		return nil
 	}
 }
 
-func replaceInFilesConcurrently(files []string, stdin io.Reader, stdout io.Writer, args Args, config Config) error {
-	jobs := make(chan string, len(files))
-	errors := make(chan string)
-	var wg sync.WaitGroup
+func worker(ctx context.Context, id int, run fileRun, jobs <-chan fileJob, results chan<- fileResult, confirmAnswer *ConfirmAnswer, stopWalk func()) {
+	run.config.logf("Worker %d initialized", id)
 
-	for i := range config.Workers {
-		wg.Add(1)
+	for job := range jobs {
+		// files not started once interrupted are left out of the results
+		if ctx.Err() != nil {
+			continue
+		}
 
-		go worker(i, args, &wg, stdin, stdout, config, jobs, errors)
+		results <- fileResult{index: job.index, report: run.replaceFile(ctx, job.path, confirmAnswer)}
+
+		if confirmAnswer != nil && rune(*confirmAnswer) == ConfirmQuit {
+			stopWalk()
+
+			return
+		}
 	}
+}
 
-	for _, file := range files {
-		jobs <- file
+/**
+ * replaceInFiles replaces in the files handed out by `source` while it is still looking for more. Reports are
+ * returned, and told to progress as done, in the order the files were found, no matter the order they were
+ * completed in. It also returns how many files were found, and the error that stopped `source`, if any
+ */
+func (f fileRun) replaceInFiles(ctx context.Context, source fileSource, confirmAnswer *ConfirmAnswer) (done []FileReport, found int, err error) {
+	// --max-total is honoured across all files, whether they are processed by workers or one by one
+	f.config.replaced = &atomic.Int64{}
+	f.progressMutex = &sync.Mutex{}
+
+	workers := f.config.workers()
+
+	if f.config.Confirm {
+		f.config.logf("Find/replace won't be performed concurrently as flag confirm was supplied")
+	} else {
+		f.config.logf("Number of workers set for operation: %d", workers)
+
+		// only prompts share the answer across files
+		confirmAnswer = nil
 	}
-	close(jobs)
+
+	walkCtx, stopWalk := context.WithCancel(ctx)
+	defer stopWalk()
+
+	// files found but not yet told as done are bounded, so that the ones completed out of order are not held forever
+	slots := make(chan struct{}, workers*4)
+	jobs := make(chan fileJob)
+	results := make(chan fileResult)
+
+	var walkErr error
+	walked := make(chan struct{})
 
 	go func() {
-		wg.Wait()
-		close(errors)
+		defer close(walked)
+		defer close(jobs)
+
+		walkErr = source(walkCtx, func(path string) error {
+			select {
+			case slots <- struct{}{}:
+			case <-walkCtx.Done():
+				return walkCtx.Err()
+			}
+
+			f.notify(FileQueued, FileReport{Path: path})
+
+			select {
+			case jobs <- fileJob{index: found, path: path}:
+				found++
+
+				return nil
+			case <-walkCtx.Done():
+				return walkCtx.Err()
+			}
+		})
 	}()
 
-	for error := range errors {
-		fmt.Println(error)
+	var wg sync.WaitGroup
+
+	for i := range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			worker(ctx, i, f, jobs, results, confirmAnswer, stopWalk)
+		}()
 	}
 
-	return nil
-}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-func ReplaceInFiles(files []string, stdin io.Reader, stdout io.Writer, args Args, config Config, confirmAnswer *ConfirmAnswer) error {
-	useWorkers := config.Flags["verbose"] && len(files) > 1
+	pending := map[int]FileReport{}
+	next := 0
 
-	if !config.Flags["confirm"] {
-		if useWorkers {
-			log.Printf("Number of workers set for operation: %d", config.Workers)
-		}
+	for result := range results {
+		pending[result.index] = result.report
 
-		return replaceInFilesConcurrently(files, stdin, stdout, args, config)
+		for report, ok := pending[next]; ok; report, ok = pending[next] {
+			delete(pending, next)
+			done = append(done, report)
+			f.notify(FileDone, report)
+			next++
+			<-slots
+		}
 	}
 
-	if config.Flags["verbose"] {
-		log.Printf("Find/replace won't be performed concurrently as flag confirm was supplied")
+	// files left out once interrupted leave gaps, after which the remaining reports are still told in order
+	for _, index := range slices.Sorted(maps.Keys(pending)) {
+		done = append(done, pending[index])
+		f.notify(FileDone, pending[index])
 	}
 
-	for _, file := range files {
-		replacer := NewFileReplacer(file, args.Search, args.Replace, config)
+	// on quit, the worker leaves before the walk is over
+	stopWalk()
+	<-walked
 
-		err := ReplaceInFile(replacer, stdin, stdout, confirmAnswer)
+	// the walk is stopped on purpose when interrupted or on quit
+	if ctx.Err() != nil || confirmAnswer != nil && rune(*confirmAnswer) == ConfirmQuit {
+		walkErr = nil
+	}
 
-		if err != nil {
-			return err
-		}
+	return done, found, walkErr
+}
 
-		if rune(*confirmAnswer) == ConfirmQuit {
-			break
-		}
+/**
+ * ReplaceInFiles replaces in all `files`, telling whether any of them was changed. Once `ctx` is cancelled,
+ * no other file is started, the ones in progress are left untouched and an interrupted error is returned
+ */
+func ReplaceInFiles(ctx context.Context, files []string, stdin io.Reader, stdout io.Writer, args Args, config Config, confirmAnswer *ConfirmAnswer) (replaced bool, err error) {
+	run := fileRun{args: args, config: config, stdin: stdin, stdout: stdout}
+	done, _, _ := run.replaceInFiles(ctx, listedFiles(files), confirmAnswer)
+	report := newReport(done)
+
+	if err = ctx.Err(); err != nil {
+		return report.Replaced(), NewInterruptedError(err)
 	}
 
-	return nil
+	return report.Replaced(), report.filesError(len(files))
 }
 
 func GetFilesInDir(root string, ignoreGlobs IgnoreGlobs, verbose bool) ([]string, error) {
-	fileSystem := os.DirFS(root)
+	config := NewConfig()
+	config.Verbose = verbose
+
+	return walkDir(context.Background(), root, ignoreGlobs, config)
+}
+
+func walkDir(ctx context.Context, root string, ignoreGlobs IgnoreGlobs, config Config) ([]string, error) {
 	var filepaths []string
 
-	if verbose {
-		log.Printf("Ignoring glob patterns \"%s\"\n", ignoreGlobs.String())
+	err := walkFiles(ctx, root, ignoreGlobs, config, func(path string) error {
+		filepaths = append(filepaths, path)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	err := fs.WalkDir(fileSystem, ".", func(path string, d fs.DirEntry, err error) error {
+	config.logf("Found %d files in %s", len(filepaths), root)
+
+	return filepaths, nil
+}
+
+// walkFiles hands each file under `root` to `found` as soon as it is walked into, in lexical order
+func walkFiles(ctx context.Context, root string, ignoreGlobs IgnoreGlobs, config Config, found func(path string) error) error {
+	fileSystem := subFileSystem{fileSystem: config.fileSystem(), root: root}
+
+	config.logf("Ignoring glob patterns \"%s\"\n", ignoreGlobs.String())
+
+	return fs.WalkDir(fileSystem, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return NewDirectoryReadError(d.Name())
+			return NewDirectoryReadError(filepath.Join(root, path), err)
+		}
+
+		if err = ctx.Err(); err != nil {
+			return NewInterruptedError(err)
 		}
 
 		fullpath := filepath.Join(root, path)
 		patternMatch := ignoreGlobs.MatchAny(fullpath)
 
-		if verbose && patternMatch {
-			log.Printf("Pattern matched path \"%s\"\n", path)
+		if patternMatch {
+			config.logf("Pattern matched path \"%s\"\n", path)
 		}
 
 		if !d.IsDir() && !patternMatch {
-			filepaths = append(filepaths, fullpath)
+			return found(fullpath)
 		}
 
 		return nil
 	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if verbose {
-		log.Printf("Found %d files in %s", len(filepaths), root)
-	}
-
-	return filepaths, nil
 }
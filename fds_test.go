@@ -2,110 +2,114 @@ package fds
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestReplaceInFile_RenameTmpFileToOriginalFileWhenNotNil(t *testing.T) {
-	tempDir := t.TempDir()
-	inputPath := path.Join(tempDir, "input")
-
-	createTestFile(tempDir, "input", "Lorem ipsum dolor sit amet", t)
+	fileSystem := newTestFileSystem(map[string]string{"input": "Lorem ipsum dolor sit amet"}, t)
 
-	args := Args{Path: PathArg{Value: inputPath}, Search: "Lorem", Replace: "mamãe"}
+	args := Args{Path: PathArg{Value: "input"}, Search: "Lorem", Replace: "mamãe"}
 	config := NewConfig()
-	config.Flags = map[string]bool{}
+	config.FileSystem = fileSystem
 
 	var confirmAnswer *ConfirmAnswer
+	var stdin io.Reader
 	var stdout bytes.Buffer
 
-	stdin, _ := os.Create(path.Join(tempDir, "stdin"))
-
-	var replacer = NewFileReplacer(inputPath, args.Search, args.Replace, config)
+	var replacer = NewFileReplacer("input", args.Search, args.Replace, config)
 
-	err := ReplaceInFile(replacer, stdin, &stdout, confirmAnswer)
+	_, err := ReplaceInFile(context.Background(), replacer, stdin, &stdout, confirmAnswer)
 
 	if err != nil {
 		t.Errorf("ReplaceInFile() returned an expected error '%s'\n", err)
 	}
 
-	result, err := os.ReadFile(inputPath)
+	result, err := fileSystem.ReadFile("input")
 	want := "mamãe ipsum dolor sit amet"
 
 	if result := string(result); result != want {
 		t.Errorf(`ReplaceInFile() = %q, want %q`, result, want)
 	}
+
+	if names := fileSystem.Names(); !reflect.DeepEqual(names, []string{"input"}) {
+		t.Errorf("ReplaceInFile() left files %q behind, want only the input file", names)
+	}
 }
 
 func TestReplaceInFile_LeavesFileUntouchedWhenNothingWasReplaced(t *testing.T) {
-	tempDir := t.TempDir()
-	inputPath := path.Join(tempDir, "input")
+	fileSystem := newTestFileSystem(map[string]string{"input": "Lorem ipsum dolor sit amet"}, t)
+	originalStat, err := fileSystem.Stat("input")
 
-	createTestFile(tempDir, "input", "Lorem ipsum dolor sit amet", t)
-	originalStat, err := os.Stat(inputPath)
-
-	args := Args{Path: PathArg{Value: inputPath}, Search: "no existe", Replace: "bar"}
+	args := Args{Path: PathArg{Value: "input"}, Search: "no existe", Replace: "bar"}
 	config := NewConfig()
-	config.Flags = map[string]bool{}
+	config.FileSystem = fileSystem
 
 	var confirmAnswer *ConfirmAnswer
+	var stdin io.Reader
 	var stdout bytes.Buffer
 
-	stdin, _ := os.Create(path.Join(tempDir, "stdin"))
-
-	var replacer = NewFileReplacer(inputPath, args.Search, args.Replace, config)
+	var replacer = NewFileReplacer("input", args.Search, args.Replace, config)
 
-	err = ReplaceInFile(replacer, stdin, &stdout, confirmAnswer)
+	_, err = ReplaceInFile(context.Background(), replacer, stdin, &stdout, confirmAnswer)
 
 	if err != nil {
 		t.Errorf("ReplaceInFile() returned an expected error '%s'\n", err)
 	}
 
-	statAfterReplace, err := os.Stat(inputPath)
+	statAfterReplace, err := fileSystem.Stat("input")
 
-	result, err := os.ReadFile(inputPath)
+	result, err := fileSystem.ReadFile("input")
 	want := "Lorem ipsum dolor sit amet"
 
 	if result := string(result); result != want {
 		t.Errorf(`ReplaceInFile() = %q, want %q`, result, want)
 	}
 
-	if originalStat.ModTime().Unix() < statAfterReplace.ModTime().Unix() {
+	if !statAfterReplace.ModTime().Equal(originalStat.ModTime()) {
 		t.Errorf(`ReplaceInFile() replaced input file when nothing was replaced in its content`)
 	}
 }
 
 func TestReplaceInFiles(t *testing.T) {
-	tempDir := t.TempDir()
-
 	defaultAnswer := ConfirmAnswer('n')
 
-	inputPath1 := path.Join(tempDir, "input1")
-	createTestFile(tempDir, "input1", "Lorem ipsum dolor sit amet", t)
+	inputPath1 := "/src/input1"
+	inputPath2 := "/src/input2"
 
-	inputPath2 := path.Join(tempDir, "input2")
-	createTestFile(tempDir, "input2", "Lorem ipsum dolor sit amet", t)
+	fileSystem := newTestFileSystem(map[string]string{
+		inputPath1: "Lorem ipsum dolor sit amet",
+		inputPath2: "Lorem ipsum dolor sit amet",
+	}, t)
 
+	var stdin io.Reader
 	var stdout bytes.Buffer
-	stdin, _ := os.Create(path.Join(tempDir, "stdin"))
 
 	args := Args{Path: PathArg{Value: inputPath1}, Search: "Lorem", Replace: "mamãe"}
 
 	config := NewConfig()
-	config.Flags = map[string]bool{}
+	config.FileSystem = fileSystem
 
-	err := ReplaceInFiles([]string{inputPath1, inputPath2}, stdin, &stdout, args, config, &defaultAnswer)
+	_, err := ReplaceInFiles(context.Background(), []string{inputPath1, inputPath2}, stdin, &stdout, args, config, &defaultAnswer)
 
 	if err != nil {
 		t.Errorf("ReplaceInFile() returned an expected error '%s'\n", err)
 	}
 
-	result1, err := os.ReadFile(inputPath1)
-	result2, err := os.ReadFile(inputPath1)
+	result1, err := fileSystem.ReadFile(inputPath1)
+	result2, err := fileSystem.ReadFile(inputPath2)
 
 	want := "mamãe ipsum dolor sit amet"
 
@@ -118,7 +122,112 @@ func TestReplaceInFiles(t *testing.T) {
 	}
 }
 
+func TestReplaceInFiles_MaxTotal(t *testing.T) {
+	defaultAnswer := ConfirmAnswer('n')
+
+	inputPath1 := "/src/input1"
+	inputPath2 := "/src/input2"
+
+	fileSystem := newTestFileSystem(map[string]string{
+		inputPath1: "Lorem Lorem\nLorem",
+		inputPath2: "Lorem Lorem\nLorem",
+	}, t)
+
+	var stdin io.Reader
+	var stdout bytes.Buffer
+
+	args := Args{Path: PathArg{Value: "/src"}, Search: "Lorem", Replace: "Ipsum"}
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Limits = Limits{PerLine: 1, Total: 3}
+
+	_, err := ReplaceInFiles(context.Background(), []string{inputPath1, inputPath2}, stdin, &stdout, args, config, &defaultAnswer)
+
+	if err != nil {
+		t.Errorf("ReplaceInFiles() returned an expected error '%s'\n", err)
+	}
+
+	result1, _ := fileSystem.ReadFile(inputPath1)
+	result2, _ := fileSystem.ReadFile(inputPath2)
+
+	if count := bytes.Count(slices.Concat(result1, result2), []byte("Ipsum")); count != 3 {
+		t.Errorf("ReplaceInFiles() replaced %d occurrences, want 3", count)
+	}
+
+	if count := bytes.Count(result1, []byte("Ipsum Ipsum")) + bytes.Count(result2, []byte("Ipsum Ipsum")); count > 0 {
+		t.Errorf("ReplaceInFiles() replaced more than one occurrence per line")
+	}
+}
+
+func TestReplaceInFiles_PartialFailure(t *testing.T) {
+	defaultAnswer := ConfirmAnswer('n')
+
+	inputPath := "/src/input"
+	fileSystem := newTestFileSystem(map[string]string{inputPath: "Lorem ipsum dolor sit amet"}, t)
+
+	// a directory cannot be read as a file
+	unreadablePath := "/src/unreadable"
+	fileSystem.Mkdir(unreadablePath, 0755)
+
+	var stdin io.Reader
+	var stdout bytes.Buffer
+
+	args := Args{Path: PathArg{Value: "/src"}, Search: "Lorem", Replace: "Ipsum"}
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+
+	replaced, err := ReplaceInFiles(context.Background(), []string{inputPath, unreadablePath}, stdin, &stdout, args, config, &defaultAnswer)
+
+	if !replaced {
+		t.Errorf("ReplaceInFiles() = false, want true as one of the files was replaced in")
+	}
+
+	if code := ExitCode(err); code != ExitPartialFailure {
+		t.Errorf("ReplaceInFiles() returned error %v with code %d, want code %d", err, code, ExitPartialFailure)
+	}
+
+	var filesErr FilesError
+
+	if !errors.As(err, &filesErr) || len(filesErr.Errors) != 1 {
+		t.Fatalf("ReplaceInFiles() returned error %v, want FilesError with a single error", err)
+	}
+
+	var thrownErr Error
+
+	if !errors.As(filesErr.Errors[0], &thrownErr) || thrownErr.Path != unreadablePath || !errors.Is(thrownErr, ErrFileRead) {
+		t.Errorf("ReplaceInFiles() returned error %+v, want file read error of %q", filesErr.Errors[0], unreadablePath)
+	}
+}
+
+func TestReplaceInFiles_TotalFailure(t *testing.T) {
+	fileSystem := NewMemoryFileSystem()
+
+	// directories cannot be read as files
+	unreadablePaths := []string{"/src/a", "/src/b"}
+
+	for _, path := range unreadablePaths {
+		fileSystem.Mkdir(path, 0755)
+	}
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+
+	args := Args{Path: PathArg{Value: "/src"}, Search: "Lorem", Replace: "Ipsum"}
+	replaced, err := ReplaceInFiles(context.Background(), unreadablePaths, nil, io.Discard, args, config, nil)
+
+	if replaced || !errors.Is(err, ErrTotalFailure) || errors.Is(err, ErrPartialFailure) {
+		t.Errorf("ReplaceInFiles() = %t, %v, want a total failure", replaced, err)
+	}
+
+	if code := ExitCode(err); code != ExitTotalFailure {
+		t.Errorf("ReplaceInFiles() returned error %v with code %d, want code %d", err, code, ExitTotalFailure)
+	}
+}
+
 func TestGetFilesInDir_NoIgnoreGlobs_FindAllFiles(t *testing.T) {
+	// GetFilesInDir walks the OS file system, which there is no MemoryFileSystem to replace with
 	tempDir := t.TempDir()
 
 	createTreeStructure(tempDir)
@@ -144,50 +253,51 @@ func TestGetFilesInDir_NoIgnoreGlobs_FindAllFiles(t *testing.T) {
 	}
 }
 
-func TestGetFilesInDir_IgnoreGlobs(t *testing.T) {
-	tempDir := t.TempDir()
-
-	createTreeStructure(tempDir)
+func TestWalkDir_IgnoreGlobs(t *testing.T) {
+	config := NewConfig()
+	config.FileSystem = newTestTreeStructure(t)
 
-	result, err := GetFilesInDir(
-		tempDir,
-		IgnoreGlobs{filepath.Join(tempDir, "dir2/**")},
-		false,
-	)
+	result, err := walkDir(context.Background(), "/src", IgnoreGlobs{"/src/dir2/**"}, config)
 
 	if err != nil {
-		t.Errorf("GetFilesInDir() returned expected error")
+		t.Errorf("walkDir() returned expected error")
 	}
 
 	want := []string{
-		filepath.Join(tempDir, "dir1", "file11"),
-		filepath.Join(tempDir, "dir1", "file12"),
+		filepath.Join("/src", "dir1", "file11"),
+		filepath.Join("/src", "dir1", "file12"),
 
-		filepath.Join(tempDir, "file1"),
+		filepath.Join("/src", "file1"),
 	}
 
 	if !reflect.DeepEqual(result, want) {
-		t.Errorf("GetFilesInDir() = %q, want %q", result, want)
+		t.Errorf("walkDir() = %q, want %q", result, want)
 	}
 }
 
-func TestGetFilesInDir_IgnoreGlobs_ReturnsNoFiles(t *testing.T) {
-	tempDir := t.TempDir()
-
-	createTreeStructure(tempDir)
+func TestWalkDir_IgnoreGlobs_ReturnsNoFiles(t *testing.T) {
+	config := NewConfig()
+	config.FileSystem = newTestTreeStructure(t)
 
-	result, err := GetFilesInDir(
-		tempDir,
-		IgnoreGlobs{filepath.Join(tempDir, "**")},
-		false,
-	)
+	result, err := walkDir(context.Background(), "/src", IgnoreGlobs{"/src/**"}, config)
 
 	if err != nil {
-		t.Errorf("GetFilesInDir() returned expected error")
+		t.Errorf("walkDir() returned expected error")
 	}
 
 	if count := len(result); count > 0 {
-		t.Errorf("GetFilesInDir() expects no files, returned %d files", count)
+		t.Errorf("walkDir() expects no files, returned %d files", count)
+	}
+}
+
+func TestWalkDir_NotFound(t *testing.T) {
+	config := NewConfig()
+	config.FileSystem = newTestTreeStructure(t)
+
+	_, err := walkDir(context.Background(), "/missing", IgnoreGlobs{}, config)
+
+	if !errors.Is(err, ErrDirectoryRead) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("walkDir() returned error %v, want a directory read error", err)
 	}
 }
 
@@ -203,18 +313,239 @@ func createTreeStructure(tempDir string) {
 	os.Create(path.Join(tempDir, "dir2", "file22"))
 }
 
-func createTestFile(tempDir, fileName, inputContent string, t *testing.T) *os.File {
-	var inputFile *os.File
+// newTestTreeStructure creates the tree of createTreeStructure in memory, under /src
+func newTestTreeStructure(t *testing.T) *MemoryFileSystem {
+	return newTestFileSystem(map[string]string{
+		"/src/file1":       "",
+		"/src/dir1/file11": "",
+		"/src/dir1/file12": "",
+		"/src/dir2/file21": "",
+		"/src/dir2/file22": "",
+	}, t)
+}
 
-	inputFile, err := os.Create(path.Join(tempDir, fileName))
+func TestReplaceInFile_InterruptedBeforeRename(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "Lorem ipsum dolor sit amet"}, t)
 
-	if err != nil {
-		t.Fatalf("Failed to open input file")
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Confirm = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the replacement is confirmed while being interrupted, so the temporary file is complete but must not be renamed
+	replacer := NewFileReplacer("input", "Lorem", "Ipsum", config).WithConfirm(func(Prompt) (Answer, error) {
+		cancel()
+
+		return Answer{Choice: ConfirmYes}, nil
+	})
+
+	confirmAnswer := ConfirmAnswer(ConfirmNo)
+	replaced, err := ReplaceInFile(ctx, replacer, nil, io.Discard, &confirmAnswer)
+
+	if replaced || !errors.Is(err, ErrInterrupted) || !errors.Is(err, context.Canceled) {
+		t.Errorf("ReplaceInFile() = %v, %v, want an interrupted error", replaced, err)
 	}
 
-	_, err = inputFile.WriteString(inputContent)
+	if result, _ := fileSystem.ReadFile("input"); string(result) != "Lorem ipsum dolor sit amet" {
+		t.Errorf("ReplaceInFile() = %q, want the file untouched", result)
+	}
 
-	inputFile.Seek(0, io.SeekStart)
+	if names := fileSystem.Names(); !reflect.DeepEqual(names, []string{"input"}) {
+		t.Errorf("ReplaceInFile() left files %q behind, want only the input file", names)
+	}
+}
+
+func TestReplaceInFiles_Interrupted(t *testing.T) {
+	defaultAnswer := ConfirmAnswer('n')
+
+	fileSystem := newTestFileSystem(map[string]string{"/src/input1": "Lorem", "/src/input2": "Lorem"}, t)
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	args := Args{Search: "Lorem", Replace: "Ipsum"}
+	replaced, err := ReplaceInFiles(ctx, []string{"/src/input1", "/src/input2"}, nil, io.Discard, args, config, &defaultAnswer)
+
+	if replaced || !errors.Is(err, ErrInterrupted) {
+		t.Errorf("ReplaceInFiles() = %v, %v, want no file started once interrupted", replaced, err)
+	}
+
+	if names := fileSystem.Names(); !reflect.DeepEqual(names, []string{"/src/input1", "/src/input2"}) {
+		t.Errorf("ReplaceInFiles() left files %q behind, want only the input files", names)
+	}
+}
+
+func TestReplaceInFile_LeavesNoTempFiles(t *testing.T) {
+	var tests = []struct {
+		name       string
+		fileSystem func(*MemoryFileSystem) FileSystem
+		modified   bool
+		want       error
+	}{
+		{
+			name:       "temp file write fails",
+			fileSystem: func(m *MemoryFileSystem) FileSystem { return failingFileSystem{MemoryFileSystem: m, failWrite: true} },
+			want:       ErrTempFileWrite,
+		},
+		{
+			name:       "temp file close fails",
+			fileSystem: func(m *MemoryFileSystem) FileSystem { return failingFileSystem{MemoryFileSystem: m, failClose: true} },
+			want:       ErrTempFileWrite,
+		},
+		{
+			name:       "rename fails",
+			fileSystem: func(m *MemoryFileSystem) FileSystem { return failingFileSystem{MemoryFileSystem: m, failRename: true} },
+			want:       ErrRenameFile,
+		},
+		{
+			name:       "overwriting file modified after read declined",
+			fileSystem: func(m *MemoryFileSystem) FileSystem { return m },
+			modified:   true,
+			want:       ErrAbortedOperation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			memoryFileSystem := newTestFileSystem(map[string]string{"input": "Lorem ipsum"}, t)
+
+			config := NewConfig()
+			config.FileSystem = tc.fileSystem(memoryFileSystem)
+			config.Confirm = tc.modified
+
+			// the file is modified while its match is being confirmed, then overwriting it is declined
+			replacer := NewFileReplacer("input", "Lorem", "Ipsum", config).WithConfirm(func(prompt Prompt) (Answer, error) {
+				if prompt.Match != nil {
+					memoryFileSystem.WriteFile("input", []byte("Lorem modified"), 0644)
+
+					return Answer{Choice: ConfirmYes}, nil
+				}
+
+				return Answer{Choice: ConfirmNo}, nil
+			})
+
+			confirmAnswer := ConfirmAnswer(ConfirmNo)
+			replaced, err := ReplaceInFile(context.Background(), replacer, nil, io.Discard, &confirmAnswer)
+
+			if replaced || !errors.Is(err, tc.want) {
+				t.Errorf("ReplaceInFile() = %v, %v, want error %v", replaced, err, tc.want)
+			}
+
+			if names := memoryFileSystem.Names(); !reflect.DeepEqual(names, []string{"input"}) {
+				t.Errorf("ReplaceInFile() left files %q behind, want only the input file", names)
+			}
+		})
+	}
+}
+
+func TestReplaceInFiles_LeavesNoTempFiles(t *testing.T) {
+	defaultAnswer := ConfirmAnswer('n')
+
+	files := map[string]string{}
+	var paths []string
+
+	for i := range 8 {
+		path := filepath.Join("/src", fmt.Sprintf("input%d", i))
+		files[path] = "Lorem ipsum"
+		paths = append(paths, path)
+	}
 
-	return inputFile
+	memoryFileSystem := newTestFileSystem(files, t)
+
+	config := NewConfig()
+	config.FileSystem = failingFileSystem{MemoryFileSystem: memoryFileSystem, failRename: true}
+
+	args := Args{Search: "Lorem", Replace: "Ipsum"}
+	_, err := ReplaceInFiles(context.Background(), paths, nil, io.Discard, args, config, &defaultAnswer)
+
+	var filesErr FilesError
+
+	if !errors.As(err, &filesErr) || len(filesErr.Errors) != len(paths) {
+		t.Errorf("ReplaceInFiles() returned error %v, want a rename error for each file", err)
+	}
+
+	if names := memoryFileSystem.Names(); !reflect.DeepEqual(names, paths) {
+		t.Errorf("ReplaceInFiles() left files %q behind, want only the input files", names)
+	}
+}
+
+func TestReplaceInFiles_ReportsInFoundOrder(t *testing.T) {
+	files := map[string]string{}
+
+	for i := range 50 {
+		files[fmt.Sprintf("/src/dir%d/input%02d", i%3, i)] = strings.Repeat("Lorem ipsum\n", i*10)
+	}
+
+	config := NewConfig()
+	config.FileSystem = newTestFileSystem(files, t)
+	config.Workers = 8
+
+	var doneOrder []string
+
+	run := fileRun{args: Args{Search: "Lorem", Replace: "Ipsum"}, config: config}
+	run.progress = func(progress Progress) {
+		if progress.Stage == FileDone {
+			doneOrder = append(doneOrder, progress.File.Path)
+		}
+	}
+
+	source := func(ctx context.Context, found func(path string) error) error {
+		return walkFiles(ctx, "/src", IgnoreGlobs{}, config, found)
+	}
+
+	done, found, err := run.replaceInFiles(context.Background(), source, nil)
+
+	if err != nil || found != len(files) {
+		t.Fatalf("replaceInFiles() found %d files with error %v, want %d files", found, err, len(files))
+	}
+
+	var reported []string
+
+	for _, report := range done {
+		reported = append(reported, report.Path)
+	}
+
+	if want := slices.Sorted(maps.Keys(files)); !reflect.DeepEqual(reported, want) || !reflect.DeepEqual(doneOrder, want) {
+		t.Errorf("replaceInFiles() reported %q and told %q as done, want %q", reported, doneOrder, want)
+	}
+}
+
+func TestReplaceInFiles_StreamsFilesWhileWalking(t *testing.T) {
+	config := NewConfig()
+	config.FileSystem = newTestFileSystem(map[string]string{"/src/a": "Lorem", "/src/b": "Lorem"}, t)
+
+	started := make(chan struct{})
+
+	run := fileRun{args: Args{Search: "Lorem", Replace: "Ipsum"}, config: config}
+	run.progress = func(progress Progress) {
+		if progress.Stage == FileStarted && progress.File.Path == "/src/a" {
+			close(started)
+		}
+	}
+
+	// the second file is only found once the first one was started
+	source := func(ctx context.Context, found func(path string) error) error {
+		if err := found("/src/a"); err != nil {
+			return err
+		}
+
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			return errors.New("first file was not started before the walk was over")
+		}
+
+		return found("/src/b")
+	}
+
+	done, _, err := run.replaceInFiles(context.Background(), source, nil)
+
+	if err != nil || len(done) != 2 {
+		t.Errorf("replaceInFiles() = %+v, %v, want both files replaced in", done, err)
+	}
 }
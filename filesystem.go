@@ -0,0 +1,414 @@
+package fds
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * FileSystem is what files are read, walked and written through. Names are paths as supplied on the command line,
+ * either relative or absolute, rather than the slash-separated paths of fs.FS
+ */
+type FileSystem interface {
+	Open(name string) (fs.File, error)
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	EvalSymlinks(name string) (string, error)
+
+	// CreateTemp creates a new file as os.CreateTemp does. An empty `dir` stands for the directory of temporary files
+	CreateTemp(dir, pattern string) (TempFile, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+}
+
+// TempFile is where the result of a replacement is written before being renamed into the original file
+type TempFile interface {
+	io.Writer
+	io.Closer
+	Name() string
+}
+
+// discardTempFile closes and removes a temporary file that is not going to be renamed into its original file
+func discardTempFile(fileSystem FileSystem, tmpFile TempFile) {
+	tmpFile.Close()
+	fileSystem.Remove(tmpFile.Name())
+}
+
+// OSFileSystem is the FileSystem of the operating system, used unless another one is set in Config
+type OSFileSystem struct{}
+
+func (OSFileSystem) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (OSFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFileSystem) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (OSFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (OSFileSystem) EvalSymlinks(name string) (string, error) {
+	return filepath.EvalSymlinks(name)
+}
+
+func (OSFileSystem) CreateTemp(dir, pattern string) (TempFile, error) {
+	file, err := os.CreateTemp(dir, pattern)
+
+	if err != nil {
+		// a nil *os.File would not make a nil TempFile
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (OSFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+/**
+ * MemoryFileSystem keeps files in memory, for tests and for replacing in trees that are not on disk.
+ * Directories are created along with the files in them, and cannot be opened. It has no symbolic links
+ */
+type MemoryFileSystem struct {
+	mutex   sync.Mutex
+	files   map[string]*memoryFile
+	created int
+
+	// TempDir is where temporary files are created when no directory is supplied
+	TempDir string
+}
+
+type memoryFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func NewMemoryFileSystem() *MemoryFileSystem {
+	return &MemoryFileSystem{files: map[string]*memoryFile{}, TempDir: "/tmp"}
+}
+
+// WriteFile creates or truncates the file `name`, along with its parent directories
+func (m *MemoryFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name = filepath.Clean(name)
+
+	if file, ok := m.files[name]; ok && file.mode.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+	}
+
+	m.makeParents(name)
+	m.files[name] = &memoryFile{data: slices.Clone(data), mode: perm, modTime: time.Now()}
+
+	return nil
+}
+
+func (m *MemoryFileSystem) ReadFile(name string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	file, err := m.find("read", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if file.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+
+	return slices.Clone(file.data), nil
+}
+
+// Mkdir creates the directory `name`, along with its parent directories
+func (m *MemoryFileSystem) Mkdir(name string, perm fs.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name = filepath.Clean(name)
+
+	if _, ok := m.files[name]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+
+	m.makeParents(name)
+	m.files[name] = &memoryFile{mode: fs.ModeDir | perm, modTime: time.Now()}
+
+	return nil
+}
+
+// Names lists the names of all files, directories excluded, sorted
+func (m *MemoryFileSystem) Names() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var names []string
+
+	for name, file := range m.files {
+		if !file.mode.IsDir() {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+func (m *MemoryFileSystem) Open(name string) (fs.File, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	file, err := m.find("open", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// directories are only read through ReadDir
+	if file.mode.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+
+	info := memoryFileInfo{name: filepath.Base(name), file: *file}
+
+	return &memoryOpenFile{info: info, reader: strings.NewReader(string(file.data))}, nil
+}
+
+func (m *MemoryFileSystem) Stat(name string) (fs.FileInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	file, err := m.find("stat", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return memoryFileInfo{name: filepath.Base(name), file: *file}, nil
+}
+
+func (m *MemoryFileSystem) Lstat(name string) (fs.FileInfo, error) {
+	return m.Stat(name)
+}
+
+func (m *MemoryFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	dir, err := m.find("readdir", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !dir.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	var entries []fs.DirEntry
+	name = filepath.Clean(name)
+
+	for path, file := range m.files {
+		if path != name && filepath.Dir(path) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(memoryFileInfo{name: filepath.Base(path), file: *file}))
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+
+	return entries, nil
+}
+
+func (m *MemoryFileSystem) EvalSymlinks(name string) (string, error) {
+	if _, err := m.Stat(name); err != nil {
+		return "", err
+	}
+
+	return filepath.Clean(name), nil
+}
+
+func (m *MemoryFileSystem) CreateTemp(dir, pattern string) (TempFile, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if dir == "" {
+		dir = m.TempDir
+	}
+
+	m.created++
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	name := filepath.Join(dir, prefix+strconv.Itoa(m.created)+suffix)
+
+	m.makeParents(name)
+	m.files[name] = &memoryFile{mode: 0600, modTime: time.Now()}
+
+	return &memoryTempFile{fileSystem: m, name: name}, nil
+}
+
+func (m *MemoryFileSystem) Rename(oldpath, newpath string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	file, err := m.find("rename", oldpath)
+
+	if err != nil {
+		return err
+	}
+
+	newpath = filepath.Clean(newpath)
+
+	if target, ok := m.files[newpath]; ok && target.mode.IsDir() {
+		return &fs.PathError{Op: "rename", Path: newpath, Err: errors.New("is a directory")}
+	}
+
+	delete(m.files, filepath.Clean(oldpath))
+	m.makeParents(newpath)
+	m.files[newpath] = file
+
+	return nil
+}
+
+func (m *MemoryFileSystem) Remove(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	file, err := m.find("remove", name)
+
+	if err != nil {
+		return err
+	}
+
+	name = filepath.Clean(name)
+
+	for path := range m.files {
+		if file.mode.IsDir() && path != name && filepath.Dir(path) == name {
+			return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+
+	delete(m.files, name)
+
+	return nil
+}
+
+func (m *MemoryFileSystem) find(op, name string) (*memoryFile, error) {
+	file, ok := m.files[filepath.Clean(name)]
+
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return file, nil
+}
+
+func (m *MemoryFileSystem) makeParents(name string) {
+	for dir := filepath.Dir(name); ; dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; !ok {
+			m.files[dir] = &memoryFile{mode: fs.ModeDir | 0755, modTime: time.Now()}
+		}
+
+		if dir == filepath.Dir(dir) {
+			return
+		}
+	}
+}
+
+type memoryFileInfo struct {
+	name string
+	file memoryFile
+}
+
+func (i memoryFileInfo) Name() string       { return i.name }
+func (i memoryFileInfo) Size() int64        { return int64(len(i.file.data)) }
+func (i memoryFileInfo) Mode() fs.FileMode  { return i.file.mode }
+func (i memoryFileInfo) ModTime() time.Time { return i.file.modTime }
+func (i memoryFileInfo) IsDir() bool        { return i.file.mode.IsDir() }
+func (i memoryFileInfo) Sys() any           { return nil }
+
+// memoryOpenFile reads a snapshot of the file taken when it was opened
+type memoryOpenFile struct {
+	info   memoryFileInfo
+	reader *strings.Reader
+}
+
+func (f *memoryOpenFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memoryOpenFile) Read(b []byte) (int, error) {
+	return f.reader.Read(b)
+}
+
+func (f *memoryOpenFile) Close() error {
+	return nil
+}
+
+type memoryTempFile struct {
+	fileSystem *MemoryFileSystem
+	name       string
+}
+
+func (f *memoryTempFile) Write(b []byte) (int, error) {
+	f.fileSystem.mutex.Lock()
+	defer f.fileSystem.mutex.Unlock()
+
+	file, err := f.fileSystem.find("write", f.name)
+
+	if err != nil {
+		return 0, err
+	}
+
+	file.data = append(file.data, b...)
+	file.modTime = time.Now()
+
+	return len(b), nil
+}
+
+func (f *memoryTempFile) Close() error {
+	return nil
+}
+
+func (f *memoryTempFile) Name() string {
+	return f.name
+}
+
+// subFileSystem presents the directory `root` of a FileSystem as an fs.FS, so that it can be walked with fs.WalkDir
+type subFileSystem struct {
+	fileSystem FileSystem
+	root       string
+}
+
+func (s subFileSystem) Open(name string) (fs.File, error) {
+	return s.fileSystem.Open(filepath.Join(s.root, name))
+}
+
+func (s subFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return s.fileSystem.Stat(filepath.Join(s.root, name))
+}
+
+func (s subFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return s.fileSystem.ReadDir(filepath.Join(s.root, name))
+}
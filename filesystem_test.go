@@ -0,0 +1,140 @@
+package fds
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+// newTestFileSystem creates an in-memory file system holding `files`, keyed by their names
+func newTestFileSystem(files map[string]string, t testing.TB) *MemoryFileSystem {
+	fileSystem := NewMemoryFileSystem()
+
+	for name, content := range files {
+		if err := fileSystem.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file %s: %s", name, err)
+		}
+	}
+
+	return fileSystem
+}
+
+func TestMemoryFileSystem_ReadDir(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"/src/b": "", "/src/a": "", "/src/dir/c": "", "/other": ""}, t)
+
+	entries, err := fileSystem.ReadDir("/src")
+
+	if err != nil {
+		t.Fatalf("ReadDir() returned an unexpected error '%s'", err)
+	}
+
+	var names []string
+
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	if want := []string{"a", "b", "dir"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ReadDir() = %q, want %q", names, want)
+	}
+
+	if !entries[2].IsDir() {
+		t.Errorf("ReadDir() did not tell %q is a directory", entries[2].Name())
+	}
+}
+
+func TestMemoryFileSystem_CreateTempAndRename(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "foo"}, t)
+
+	tmpFile, err := fileSystem.CreateTemp("", "input")
+
+	if err != nil {
+		t.Fatalf("CreateTemp() returned an unexpected error '%s'", err)
+	}
+
+	io.WriteString(tmpFile, "bar")
+	tmpFile.Close()
+
+	if err = fileSystem.Rename(tmpFile.Name(), "input"); err != nil {
+		t.Fatalf("Rename() returned an unexpected error '%s'", err)
+	}
+
+	if result, _ := fileSystem.ReadFile("input"); string(result) != "bar" {
+		t.Errorf("ReadFile() = %q after rename, want %q", result, "bar")
+	}
+
+	if _, err = fileSystem.Stat(tmpFile.Name()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat() of the renamed temporary file returned error %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestMemoryFileSystem_OpenDirectory(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"/src/input": "foo"}, t)
+
+	if _, err := fileSystem.Open("/src"); err == nil {
+		t.Errorf("Open() of a directory did not return an error")
+	}
+}
+
+func TestMemoryFileSystem_Remove(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "foo"}, t)
+
+	if err := fileSystem.Remove("input"); err != nil {
+		t.Fatalf("Remove() returned an unexpected error '%s'", err)
+	}
+
+	if err := fileSystem.Remove("input"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove() of a removed file returned error %v, want fs.ErrNotExist", err)
+	}
+}
+
+var errTestFailure = errors.New("test failure")
+
+// failingFileSystem fails the operations set on temporary files, to test that none of them is left behind
+type failingFileSystem struct {
+	*MemoryFileSystem
+
+	failWrite, failClose, failRename bool
+}
+
+func (f failingFileSystem) CreateTemp(dir, pattern string) (TempFile, error) {
+	tmpFile, err := f.MemoryFileSystem.CreateTemp(dir, pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return failingTempFile{TempFile: tmpFile, failWrite: f.failWrite, failClose: f.failClose}, nil
+}
+
+func (f failingFileSystem) Rename(oldpath, newpath string) error {
+	if f.failRename {
+		return errTestFailure
+	}
+
+	return f.MemoryFileSystem.Rename(oldpath, newpath)
+}
+
+type failingTempFile struct {
+	TempFile
+
+	failWrite, failClose bool
+}
+
+func (f failingTempFile) Write(b []byte) (int, error) {
+	if f.failWrite {
+		return 0, errTestFailure
+	}
+
+	return f.TempFile.Write(b)
+}
+
+func (f failingTempFile) Close() error {
+	if f.failClose {
+		return errTestFailure
+	}
+
+	return f.TempFile.Close()
+}
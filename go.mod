@@ -3,13 +3,15 @@ module github.com/gabrieloliverio/fds
 go 1.24.0
 
 require (
+	github.com/BurntSushi/toml v1.5.0 // direct
 	github.com/bmatcuk/doublestar/v4 v4.8.1 // direct
 	github.com/fatih/color v1.18.0 // direct
+	github.com/mattn/go-isatty v0.0.20 // direct
+	golang.org/x/sys v0.25.0 // direct
+	golang.org/x/term v0.24.0 // direct
+	golang.org/x/text v0.28.0 // direct
 )
 
-require (
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/spf13/pflag v1.0.6 // indirect
-	golang.org/x/sys v0.25.0 // indirect
-)
+require github.com/spf13/pflag v1.0.6
+
+require github.com/mattn/go-colorable v0.1.13 // indirect
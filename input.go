@@ -11,13 +11,33 @@ import (
 )
 
 const (
-	LiteralUsage     = "Treat pattern as a regular string instead of as Regular Expression"
-	ConfirmUsage     = "Confirm each substitution"
-	InsensitiveUsage = "Ignore case on search"
-	VerboseUsage     = "Print debug information"
-	IgnoreUsage      = "Ignore glob patterns, comma-separated. Ex. --ignore-globs \"vendor/**,node_modules/lib/**.js\""
-	HelpUsage        = "Print out help"
-	WorkersUsage     = "Number of workers created to process the substitutions. Default value: 4"
+	LiteralUsage      = "Treat pattern as a regular string instead of as Regular Expression"
+	ConfirmUsage      = "Confirm each substitution"
+	InsensitiveUsage  = "Ignore case on search"
+	PreserveCaseUsage = "Match ignoring case and adapt the replacement to the case of each match (lower, UPPER, Title, camelCase)"
+	VerboseUsage      = "Print debug information"
+	IgnoreUsage       = "Ignore glob patterns, comma-separated. Ex. --ignore-globs \"vendor/**,node_modules/lib/**.js\""
+	HelpUsage         = "Print out help"
+	WorkersUsage      = "Number of workers created to process the substitutions, and to read directories ahead. 0 creates one per CPU. Default value: 4"
+	MaxPerLineUsage   = "Maximum number of replacements per line, like sed without the g flag when set to 1. Default value: 0 (unlimited)"
+	MaxPerFileUsage   = "Maximum number of replacements per file. Default value: 0 (unlimited)"
+	MaxTotalUsage     = "Maximum number of replacements across all files. Default value: 0 (unlimited)"
+	LinesUsage        = "Only replace in the range of lines supplied. Ex. --lines 10:20, --lines 10: or --lines :20"
+	AfterUsage        = "Only replace in lines after a line matching the pattern supplied, up to --before-pattern if any"
+	BeforeUsage       = "Only replace in lines before a line matching the pattern supplied, from --after-pattern if any"
+	WhereUsage        = "Only replace in lines matching the pattern supplied. Ex. --where '^import'"
+	DeleteLineUsage   = "Delete lines matching the pattern instead of replacing it. No replace argument is taken"
+	InsertBeforeUsage = "Insert the text supplied as a line before each line matching the pattern. No replace argument is taken"
+	InsertAfterUsage  = "Insert the text supplied as a line after each line matching the pattern. No replace argument is taken"
+	EOLUsage          = "Normalise line endings of the files written to lf, crlf or cr. By default, line endings of each line are kept"
+	EncodingUsage     = "Encoding of files without a BOM, which are written back in the same encoding. Ex. --encoding ISO-8859-1. Default value: UTF-8"
+	ContextUsage      = "Number of lines shown before and after the line of each match being confirmed. Default value: 2"
+	NoProgressUsage   = "Do not display progress on stderr. It is only displayed on terminals, when replacing in files without --confirm or --verbose"
+	TUIUsage          = "Review all matches in a full-screen tree, selecting the ones to replace before applying them"
+	AnswersUsage      = "Answer confirmations from a file recorded with --record-answers, or from a sequence of answers such as \"yyn a\", instead of asking. Implies --confirm"
+	RecordUsage       = "Record the answers to confirmations into the file supplied, to be replayed with --answers. Implies --confirm"
+	ColorUsage        = "Color output: auto, always or never. Default value: auto (on terminals only, unless NO_COLOR is set)"
+	OutputFormatUsage = "Print the outcome of replacing in files: text prints nothing more, json prints each file along with its replacements and error. Default value: text"
 )
 
 var Usage = fmt.Sprintf(`fds is modern and opinionated find/replace CLI program
@@ -27,17 +47,44 @@ Usage:
 	fds [ options ] search_pattern replace ./file
 	fds [ options ] search_pattern replace ~/directory
 	fds [ options ] search_pattern replace ~/directory/**/somepattern*
+	fds [ --delete-line | --insert-before text | --insert-after text ] [ options ] search_pattern ./file
+	fds config show
 
 Options:
 
 	-l, --literal        %s
 	-i, --insensitive    %s
+	--preserve-case      %s
 	-c, --confirm        %s
+	--tui                %s
+	--answers            %s
+	--record-answers     %s
+	-C, --context        %s
 	-v, --verbose        %s
 	--ignore-globs       %s
 	--workers            %s
+	--max-per-line       %s
+	--max-per-file       %s
+	--max-total          %s
+	--lines              %s
+	--after-pattern      %s
+	--before-pattern     %s
+	--where              %s
+	--delete-line        %s
+	--insert-before      %s
+	--insert-after       %s
+	--eol                %s
+	--encoding           %s
+	--no-progress        %s
+	--color              %s
+	--output-format      %s
 	-h, --help           %s
-`, LiteralUsage, InsensitiveUsage, ConfirmUsage, VerboseUsage, IgnoreUsage, WorkersUsage, HelpUsage)
+
+Defaults of the options are read from ~/.config/fds/config.toml, then from the closest .fds.toml. "fds config show"
+prints the configuration in effect, along with where each setting comes from
+`, LiteralUsage, InsensitiveUsage, PreserveCaseUsage, ConfirmUsage, TUIUsage, AnswersUsage, RecordUsage, ContextUsage, VerboseUsage, IgnoreUsage, WorkersUsage,
+	MaxPerLineUsage, MaxPerFileUsage, MaxTotalUsage, LinesUsage, AfterUsage, BeforeUsage, WhereUsage,
+	DeleteLineUsage, InsertBeforeUsage, InsertAfterUsage, EOLUsage, EncodingUsage, NoProgressUsage, ColorUsage, OutputFormatUsage, HelpUsage)
 
 type PathArg struct {
 	Value    string
@@ -68,23 +115,43 @@ type Args struct {
 	Path PathArg
 }
 
-func Validate(args Args, flags map[string]bool) error {
-	_, err := regexp.Compile(args.Search)
+/**
+ * Validate validates the arguments, along with the options of `config` which depend on them, such as line selections
+ * requiring files. The options are validated against each other as well, through Config.Validate
+ */
+func Validate(args Args, config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
 
-	if !flags["literal"] && err != nil {
+	if _, err := regexp.Compile(args.Search); !config.Literal && err != nil {
 		return NewInvalidRegExpError()
 	}
 
-	if flags["literal"] && flags["insensitive"] {
-		return NewLiteralInsensitiveError()
+	// line operations take the place of the replacement
+	if !config.Operations.IsSet() && strings.TrimSpace(args.Replace) == "" {
+		return NewInvalidArgumentsError()
 	}
 
-	if flags["confirm"] && args.Path.Value == "" {
-		return NewConfirmNotOnFileError()
+	if strings.TrimSpace(args.Subject) == "" || strings.TrimSpace(args.Search) == "" {
+		return NewInvalidArgumentsError()
 	}
 
-	if strings.TrimSpace(args.Replace) == "" || strings.TrimSpace(args.Subject) == "" || strings.TrimSpace(args.Search) == "" {
-		return NewInvalidArgumentsError()
+	if config.Selection.IsSet() && args.Path.Value == "" {
+		return NewSelectionNotOnFileError()
+	}
+
+	if config.Operations.IsSet() && args.Path.Value == "" {
+		return NewLineOperationNotOnFileError()
+	}
+
+	if config.Review && args.Path.Value == "" {
+		return NewReviewNotOnFileError()
+	}
+
+	// the result of replacing in stdin is what is printed
+	if config.OutputFormat == OutputJSON && args.Path.Value == "" {
+		return NewInvalidOptionError("output-format", "json can only be used when files are supplied, not with STDIN nor positional arguments")
 	}
 
 	return nil
@@ -94,7 +161,7 @@ func readStdin(stdin *os.File, inputArgs []string) (Args, error) {
 	stdInput, err := io.ReadAll(stdin)
 
 	if err != nil {
-		return Args{}, NewStdinReadError()
+		return Args{}, NewStdinReadError(err)
 	}
 
 	if len(inputArgs) < 2 {
@@ -106,7 +173,8 @@ func readStdin(stdin *os.File, inputArgs []string) (Args, error) {
 
 func ReadArgs(stdin *os.File, inputArgs []string) (Args, error) {
 	stat, _ := stdin.Stat()
-	isStdin := stat.Size() > 0
+	// pipes may not tell their size, but there is no path to replace in when only two arguments are supplied
+	isStdin := stat.Size() > 0 || (stat.Mode()&os.ModeNamedPipe != 0 && len(inputArgs) == 2)
 
 	if isStdin {
 		return readStdin(stdin, inputArgs)
@@ -116,16 +184,26 @@ func ReadArgs(stdin *os.File, inputArgs []string) (Args, error) {
 		return Args{}, NewInvalidArgumentsError()
 	}
 
-	args := Args{
-		Search:  inputArgs[0],
-		Replace: inputArgs[1],
-		Subject: inputArgs[2],
+	return readPathArgs(OSFileSystem{}, Args{Search: inputArgs[0], Replace: inputArgs[1], Subject: inputArgs[2]})
+}
+
+/**
+ * ReadLineOperationArgs reads the arguments used along with line operations, which take no replacement:
+ * `fds --delete-line search_pattern ./file`
+ */
+func ReadLineOperationArgs(inputArgs []string) (Args, error) {
+	if len(inputArgs) < 2 {
+		return Args{}, NewInvalidArgumentsError()
 	}
 
-	fileStat, err := os.Stat(args.Subject)
+	return readPathArgs(OSFileSystem{}, Args{Search: inputArgs[0], Subject: inputArgs[1]})
+}
+
+func readPathArgs(fileSystem FileSystem, args Args) (Args, error) {
+	fileStat, err := fileSystem.Stat(args.Subject)
 
 	if err != nil {
-		return Args{}, NewInvalidArgumentsErrorFileNotFound(args.Subject)
+		return Args{}, NewInvalidArgumentsErrorFileNotFound(args.Subject, err)
 	}
 
 	args.Path = PathArg{Value: args.Subject, fileInfo: fileStat}
@@ -1,16 +1,18 @@
 package fds
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path"
 	"testing"
 )
 
-func createTempFile(tempDir, inputContent string, t *testing.T) *os.File {
+// createTempFile creates a real file to be used as stdin, as ReadArgs stats it to tell content piped from paths
+func createTempFile(inputContent string, t *testing.T) *os.File {
 	var file *os.File
 
-	file, err := os.Create(path.Join(tempDir, "input"))
+	file, err := os.Create(path.Join(t.TempDir(), "input"))
 
 	if err != nil {
 		t.Fatalf("Failed to open input file")
@@ -23,15 +25,16 @@ func createTempFile(tempDir, inputContent string, t *testing.T) *os.File {
 	}
 
 	file.Seek(0, io.SeekStart)
+	t.Cleanup(func() { file.Close() })
 
 	return file
 }
 
 func TestValidate(t *testing.T) {
 	type validationInput struct {
-		args  Args
-		usage string
-		flags map[string]bool
+		args   Args
+		usage  string
+		config Config
 	}
 
 	type test []struct {
@@ -48,34 +51,34 @@ func TestValidate(t *testing.T) {
 				args:  Args{Subject: "Foo Bar", Search: "Foo", Replace: "Baz"},
 				usage: "",
 
-				flags: map[string]bool{"literal": false, "insensitive": false, "confirm": false},
+				config: Config{},
 			},
 			expectError: false,
 		},
 		{
 			name: "Valid subject, search, replace. Literal flag true",
 			input: validationInput{
-				args:  Args{Subject: "Foo Bar", Search: "Foo", Replace: "Baz"},
-				usage: "",
-				flags: map[string]bool{"literal": true, "insensitive": false, "confirm": false},
+				args:   Args{Subject: "Foo Bar", Search: "Foo", Replace: "Baz"},
+				usage:  "",
+				config: Config{Literal: true},
 			},
 			expectError: false,
 		},
 		{
 			name: "Valid subject, search, replace. Insensitive flag true",
 			input: validationInput{
-				args:  Args{Subject: "Foo Bar", Search: "Foo", Replace: "Baz"},
-				usage: "",
-				flags: map[string]bool{"literal": false, "insensitive": true, "confirm": false},
+				args:   Args{Subject: "Foo Bar", Search: "Foo", Replace: "Baz"},
+				usage:  "",
+				config: Config{Insensitive: true},
 			},
 			expectError: false,
 		},
 		{
 			name: "Valid subject (file content), search, replace. Confirm flag true",
 			input: validationInput{
-				args:  Args{Path: PathArg{Value: "./foo"}, Subject: "Foo", Search: "Foo", Replace: "Baz"},
-				usage: "",
-				flags: map[string]bool{"literal": false, "insensitive": false, "confirm": true},
+				args:   Args{Path: PathArg{Value: "./foo"}, Subject: "Foo", Search: "Foo", Replace: "Baz"},
+				usage:  "",
+				config: Config{Confirm: true},
 			},
 			expectError: false,
 		},
@@ -83,54 +86,54 @@ func TestValidate(t *testing.T) {
 		{
 			name: "Insensitive and literal flag true",
 			input: validationInput{
-				args:  Args{Subject: "Foo Bar", Search: "Foo", Replace: "Baz"},
-				usage: "",
-				flags: map[string]bool{"literal": true, "insensitive": true, "confirm": false},
+				args:   Args{Subject: "Foo Bar", Search: "Foo", Replace: "Baz"},
+				usage:  "",
+				config: Config{Literal: true, Insensitive: true},
 			},
 			expectError: true,
 		},
 		{
 			name: "No Subject",
 			input: validationInput{
-				args:  Args{Subject: "", Search: "Foo", Replace: "Baz"},
-				usage: "",
-				flags: map[string]bool{"literal": false, "insensitive": false, "confirm": false},
+				args:   Args{Subject: "", Search: "Foo", Replace: "Baz"},
+				usage:  "",
+				config: Config{},
 			},
 			expectError: true,
 		},
 		{
 			name: "No search",
 			input: validationInput{
-				args:  Args{Subject: "Foo Bar", Search: "", Replace: "Baz"},
-				usage: "",
-				flags: map[string]bool{"literal": false, "insensitive": false, "confirm": false},
+				args:   Args{Subject: "Foo Bar", Search: "", Replace: "Baz"},
+				usage:  "",
+				config: Config{},
 			},
 			expectError: true,
 		},
 		{
 			name: "No replace",
 			input: validationInput{
-				args:  Args{Subject: "Foo Bar", Search: "Foo", Replace: ""},
-				usage: "",
-				flags: map[string]bool{"literal": false, "insensitive": false, "confirm": false},
+				args:   Args{Subject: "Foo Bar", Search: "Foo", Replace: ""},
+				usage:  "",
+				config: Config{},
 			},
 			expectError: true,
 		},
 		{
-			name: "Confirm flag without file",
+			name: "Confirm flag without file, confirming on the terminal",
 			input: validationInput{
-				args:  Args{Subject: "Foo Bar", Search: "Foo", Replace: ""},
-				usage: "",
-				flags: map[string]bool{"literal": false, "insensitive": false, "confirm": true},
+				args:   Args{Subject: "Foo Bar", Search: "Foo", Replace: "Baz"},
+				usage:  "",
+				config: Config{Confirm: true},
 			},
-			expectError: true,
+			expectError: false,
 		},
 		{
 			name: "Invalid regexp",
 			input: validationInput{
-				args:  Args{Subject: "Foo Bar", Search: "((no bueno)", Replace: ""},
-				usage: "",
-				flags: map[string]bool{"literal": false, "insensitive": false, "confirm": false},
+				args:   Args{Subject: "Foo Bar", Search: "((no bueno)", Replace: ""},
+				usage:  "",
+				config: Config{},
 			},
 			expectError: true,
 		},
@@ -140,7 +143,7 @@ func TestValidate(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			err := Validate(
 				tc.input.args,
-				tc.input.flags,
+				tc.input.config,
 			)
 
 			if err != nil && !tc.expectError {
@@ -154,8 +157,112 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidate_SelectionRequiresFile(t *testing.T) {
+	config := NewConfig()
+	config.Selection.Lines.Set("1:2")
+
+	args := Args{Subject: "Foo Bar", Search: "Foo", Replace: "Baz"}
+
+	if err := Validate(args, config); err == nil {
+		t.Errorf("Validate() expects error when selecting lines from STDIN, none returned")
+	}
+
+	args.Path = PathArg{Value: "./foo"}
+
+	if err := Validate(args, config); err != nil {
+		t.Errorf("Validate() does not expect error when selecting lines from a file, got %s", err)
+	}
+}
+
+func TestValidate_LineOperationsTakeNoReplace(t *testing.T) {
+	config := NewConfig()
+	config.Operations = LineOperations{Delete: true}
+
+	args := Args{Path: PathArg{Value: "./foo"}, Subject: "./foo", Search: "Foo"}
+
+	if err := Validate(args, config); err != nil {
+		t.Errorf("Validate() does not expect error for line operations without replace, got %s", err)
+	}
+
+	args.Path = PathArg{}
+
+	if err := Validate(args, config); err == nil {
+		t.Errorf("Validate() expects error for line operations on STDIN, none returned")
+	}
+}
+
+func TestValidate_OptionsRequiringFiles(t *testing.T) {
+	var tests = []struct {
+		name    string
+		config  func(config *Config)
+		wantErr error
+	}{
+		{name: "selection", config: func(config *Config) { config.Selection.Lines.Set("1:2") }, wantErr: ErrSelectionNotOnFile},
+		{name: "line operations", config: func(config *Config) { config.Operations.InsertAfter = "Baz" }, wantErr: ErrLineOperationNotOnFile},
+		{name: "review", config: func(config *Config) { config.Confirm, config.Review = true, true }, wantErr: ErrReviewNotOnFile},
+		{name: "json output", config: func(config *Config) { config.OutputFormat = OutputJSON }, wantErr: ErrInvalidOption},
+		{name: "confirm", config: func(config *Config) { config.Confirm = true }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config := NewConfig()
+			tc.config(&config)
+
+			stdin := Args{Subject: "Foo Bar", Search: "Foo", Replace: "Baz"}
+
+			if err := Validate(stdin, config); tc.wantErr != nil && !errors.Is(err, tc.wantErr) || tc.wantErr == nil && err != nil {
+				t.Errorf("Validate() on STDIN returned error %v, want %v", err, tc.wantErr)
+			}
+
+			file := Args{Path: PathArg{Value: "./foo"}, Subject: "./foo", Search: "Foo", Replace: "Baz"}
+
+			if err := Validate(file, config); err != nil {
+				t.Errorf("Validate() on a file returned an unexpected error '%s'", err)
+			}
+		})
+	}
+}
+
+func TestReadLineOperationArgs(t *testing.T) {
+	// paths supplied on the command line are looked up on the OS file system
+	tempDir := t.TempDir()
+
+	file, _ := os.Create(path.Join(tempDir, "file"))
+
+	result, err := ReadLineOperationArgs([]string{"search", file.Name()})
+
+	if err != nil {
+		t.Fatalf("ReadLineOperationArgs() does not expect error, got %s", err)
+	}
+
+	if result.Search != "search" || result.Replace != "" || result.Path.Value != file.Name() {
+		t.Errorf(`ReadLineOperationArgs() = "%+v", want search and path`, result)
+	}
+}
+
 func TestReadArgs_Stdin(t *testing.T) {
-	stdin := createTempFile(os.TempDir(), "my subject", t)
+	stdin := createTempFile("my subject", t)
+
+	want := Args{Subject: "my subject", Search: "search", Replace: "replace"}
+	result, _ := ReadArgs(stdin, []string{"search", "replace"})
+
+	if result != want {
+		t.Errorf(`ReadArgs() = "%+v", want "%+v"`, result, want)
+	}
+}
+
+func TestReadArgs_Pipe(t *testing.T) {
+	stdin, writer, err := os.Pipe()
+
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %s", err)
+	}
+
+	defer stdin.Close()
+
+	writer.WriteString("my subject")
+	writer.Close()
 
 	want := Args{Subject: "my subject", Search: "search", Replace: "replace"}
 	result, _ := ReadArgs(stdin, []string{"search", "replace"})
@@ -166,13 +273,13 @@ func TestReadArgs_Stdin(t *testing.T) {
 }
 
 func TestReadArgs_File(t *testing.T) {
-	tempDir := os.TempDir()
+	// paths supplied on the command line are looked up on the OS file system
+	file, _ := os.Create(path.Join(t.TempDir(), "file"))
+	defer file.Close()
 
-	file, _ := os.Create(path.Join(tempDir, "file"))
 	file.WriteString("Lorem ipsum")
-	file.Seek(0, io.SeekStart)
 
-	stdin := createTempFile(tempDir, "", t)
+	stdin := createTempFile("", t)
 	result, _ := ReadArgs(stdin, []string{"search", "replace", file.Name()})
 
 	if result.Path.Value != file.Name() || result.Subject != file.Name() {
@@ -181,7 +288,7 @@ func TestReadArgs_File(t *testing.T) {
 }
 
 func TestReadArgs_Stdin_NoParametersReturnError(t *testing.T) {
-	stdin := createTempFile(os.TempDir(), "my subject", t)
+	stdin := createTempFile("my subject", t)
 
 	_, err := ReadArgs(stdin, []string{})
 
@@ -191,7 +298,7 @@ func TestReadArgs_Stdin_NoParametersReturnError(t *testing.T) {
 }
 
 func TestReadArgs_FileNotFound(t *testing.T) {
-	stdin := createTempFile(os.TempDir(), "", t)
+	stdin := createTempFile("", t)
 
 	_, err := ReadArgs(stdin, []string{"search", "replace", "./file_not_found"})
 
@@ -201,7 +308,7 @@ func TestReadArgs_FileNotFound(t *testing.T) {
 }
 
 func TestReadArgs_NoParametersReturnError(t *testing.T) {
-	stdin := createTempFile(os.TempDir(), "", t)
+	stdin := createTempFile("", t)
 
 	_, err := ReadArgs(stdin, []string{})
 
@@ -285,21 +392,20 @@ func TestPathArg(t *testing.T) {
 		isFile bool
 	}
 
-	tempDir := t.TempDir()
-	tempDirStat, _ := os.Stat(tempDir)
+	fileSystem := newTestFileSystem(map[string]string{"/src/file1": ""}, t)
 
-	file1, _ := os.CreateTemp(tempDir, "")
-	fileStat1, _ := file1.Stat()
+	dirStat, _ := fileSystem.Stat("/src")
+	fileStat1, _ := fileSystem.Stat("/src/file1")
 
 	tests := []test{
 		{
-			value:    file1.Name(),
+			value:    "/src/file1",
 			fileInfo: fileStat1,
 			isFile:   true,
 		},
 		{
-			value:    tempDir,
-			fileInfo: tempDirStat,
+			value:    "/src",
+			fileInfo: dirStat,
 			isDir:    true,
 		},
 		{
@@ -0,0 +1,73 @@
+package fds
+
+import "sync/atomic"
+
+// replaceBudget keeps count of the replacements performed so far against the configured Limits
+type replaceBudget struct {
+	limits Limits
+	line   int
+	file   int
+	total  *atomic.Int64
+}
+
+func newReplaceBudget(limits Limits, total *atomic.Int64) *replaceBudget {
+	if total == nil {
+		total = &atomic.Int64{}
+	}
+
+	return &replaceBudget{limits: limits, total: total}
+}
+
+func (b *replaceBudget) newLine() {
+	b.line = 0
+}
+
+// allows tells whether one more replacement fits in the limits, without taking it
+func (b *replaceBudget) allows() bool {
+	return b.allowsPending(0, 0)
+}
+
+/**
+ * allowsPending tells whether one more replacement fits in the limits, on top of the ones taken and of `line` and
+ * `file` more, accepted in the current line and file but not taken yet
+ */
+func (b *replaceBudget) allowsPending(line, file int) bool {
+	if b.limits.PerLine > 0 && b.line+line >= b.limits.PerLine {
+		return false
+	}
+
+	if b.limits.PerFile > 0 && b.file+file >= b.limits.PerFile {
+		return false
+	}
+
+	if b.limits.Total > 0 && b.total.Load()+int64(file) >= int64(b.limits.Total) {
+		return false
+	}
+
+	return true
+}
+
+// take reserves one replacement, returning false when any of the limits was already reached
+func (b *replaceBudget) take() bool {
+	if !b.allows() {
+		return false
+	}
+
+	for {
+		current := b.total.Load()
+
+		if b.limits.Total > 0 && current >= int64(b.limits.Total) {
+			return false
+		}
+
+		// the total is shared between workers, so it's only incremented if no one else got there first
+		if b.total.CompareAndSwap(current, current+1) {
+			break
+		}
+	}
+
+	b.line++
+	b.file++
+
+	return true
+}
@@ -0,0 +1,66 @@
+package fds
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReplaceBudget_PerLine(t *testing.T) {
+	budget := newReplaceBudget(Limits{PerLine: 1}, nil)
+
+	if !budget.take() {
+		t.Errorf("replaceBudget.take() = false, want true for the first replacement in the line")
+	}
+
+	if budget.take() {
+		t.Errorf("replaceBudget.take() = true, want false for the second replacement in the line")
+	}
+
+	budget.newLine()
+
+	if !budget.take() {
+		t.Errorf("replaceBudget.take() = false, want true for the first replacement in a new line")
+	}
+}
+
+func TestReplaceBudget_PerFile(t *testing.T) {
+	budget := newReplaceBudget(Limits{PerFile: 2}, nil)
+
+	budget.take()
+	budget.newLine()
+	budget.take()
+	budget.newLine()
+
+	if budget.allows() {
+		t.Errorf("replaceBudget.allows() = true, want false after reaching the limit per file")
+	}
+}
+
+func TestReplaceBudget_TotalIsSharedConcurrently(t *testing.T) {
+	var total atomic.Int64
+	var taken atomic.Int64
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			budget := newReplaceBudget(Limits{Total: 10}, &total)
+
+			for range 100 {
+				if budget.take() {
+					taken.Add(1)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if result := taken.Load(); result != 10 {
+		t.Errorf("replaceBudget.take() granted %d replacements, want 10", result)
+	}
+}
@@ -3,13 +3,16 @@ package fds
 import (
 	"fmt"
 	"io"
+	"os"
 	"regexp"
+	"strings"
 
 	"github.com/fatih/color"
 )
 
 type MatchString struct {
-	Search     string
+	Search string
+	// Replace is what the match is replaced with, its `$1`-like references expanded
 	Replace    string
 	Before     string
 	After      string
@@ -17,30 +20,126 @@ type MatchString struct {
 
 	IndexStart int
 	IndexEnd   int
+
+	// submatches holds the indexes of the groups of the match in the line, from which the replacement is expanded
+	submatches []int
 }
 
-func ConfirmMatch(match MatchString, filename string, lineNumber int, stdin io.Reader, stdout io.Writer) (rune, error) {
+const confirmMatchHelp = `y - replace this match
+n - do not replace this match
+a - replace this match and all the remaining ones, in all files
+A - replace this match and all the remaining ones in this file
+d - do not replace this match nor any of the remaining ones in this file
+e - edit the replacement of this match, in $EDITOR when set
+u - undo the previous answer in this file
+/ - jump to the next match on a line matching a pattern
+q - quit, replacing nothing else
+? - print help
+`
+
+/**
+ * ConfirmMatch shows the match of `prompt` along with its context lines, and asks what to do with it. Help is
+ * printed and asked again until another answer is given. The replacement of ConfirmEdit is edited in $EDITOR when
+ * set, or typed in otherwise, and the pattern of ConfirmJump is typed in
+ */
+func ConfirmMatch(prompt Prompt, stdin io.Reader, stdout io.Writer) (Answer, error) {
 	red := color.New(color.FgHiRed, color.Bold, color.Italic)
+	group := color.New(color.FgHiRed, color.Bold, color.Italic, color.Underline)
 	green := color.New(color.FgHiGreen, color.Bold)
 
-	fmt.Fprintf(stdout, "File\t%s\n", filename)
-	fmt.Fprintf(stdout, "%d\t%s%s%s%s\n", lineNumber, match.Before, red.Sprint(match.Search), green.Sprint(match.Replace), match.After)
+	match := *prompt.Match
+	reader := newAnswerReader(stdin)
+	firstLine := prompt.LineNumber - len(prompt.ContextBefore)
+
+	if prompt.Notice != "" {
+		fmt.Fprintln(stdout, prompt.Notice)
+	}
 
-	confirmText := "[y]es [n]o [a]ll q[uit]"
-	valid := []rune{'y', 'n', 'a', 'q'}
-	ret, err := Confirm(stdin, confirmText, valid)
+	fmt.Fprintf(stdout, "File\t%s\n", prompt.File)
 
-	if err != nil {
-		return 0, err
+	for i, line := range prompt.ContextBefore {
+		fmt.Fprintf(stdout, "%d\t%s\n", firstLine+i, line)
 	}
 
-	fmt.Println()
+	fmt.Fprintf(stdout, "%d\t%s%s%s%s\n", prompt.LineNumber, match.Before, highlightGroups(match, red, group), green.Sprint(match.Replace), match.After)
+
+	for i, line := range prompt.ContextAfter {
+		fmt.Fprintf(stdout, "%d\t%s\n", prompt.LineNumber+1+i, line)
+	}
+
+	confirmText := "[y,n,a,A,d,e,u,/,q,?]"
+	valid := []rune{ConfirmYes, ConfirmNo, ConfirmAll, ConfirmAllInFile, ConfirmSkipFile, ConfirmEdit, ConfirmUndo, ConfirmJump, ConfirmQuit, ConfirmMatchHelp}
+
+	for {
+		choice, err := Confirm(reader, stdout, confirmText, valid)
+
+		if err != nil {
+			return Answer{}, err
+		}
+
+		answer := Answer{Choice: choice}
+
+		switch choice {
+		case ConfirmMatchHelp:
+			fmt.Fprint(stdout, "\n"+confirmMatchHelp)
+
+			continue
+		case ConfirmEdit:
+			answer.Replacement, err = editReplacement(match.Replace, reader, stdout)
+		case ConfirmJump:
+			answer.Pattern, err = readAnswerLine(reader, stdout, "Jump to the next match on a line matching")
+		}
+
+		if err != nil {
+			return Answer{}, err
+		}
+
+		fmt.Fprintln(stdout)
+
+		return answer, nil
+	}
+}
+
+// highlightGroups prints the text of the match, the parts of it captured by groups apart from the rest of it
+func highlightGroups(match MatchString, text, group *color.Color) string {
+	captured := make([]bool, len(match.Search))
+
+	for i := 2; i+1 < len(match.submatches); i += 2 {
+		for j := max(match.submatches[i], match.IndexStart); j < match.submatches[i+1]; j++ {
+			captured[j-match.IndexStart] = true
+		}
+	}
+
+	var result strings.Builder
+
+	for start, end := 0, 0; start < len(match.Search); start = end {
+		for end < len(captured) && captured[end] == captured[start] {
+			end++
+		}
+
+		style := text
+
+		if captured[start] {
+			style = group
+		}
+
+		result.WriteString(style.Sprint(match.Search[start:end]))
+	}
+
+	return result.String()
+}
+
+func editReplacement(replace string, reader *answerReader, stdout io.Writer) (string, error) {
+	if editor := os.Getenv("EDITOR"); strings.TrimSpace(editor) != "" {
+		return editInEditor(editor, replace, reader, stdout)
+	}
 
-	return ret, nil
+	return readAnswerLine(reader, stdout, fmt.Sprintf("Replace with (currently %q)", replace))
 }
 
+// FindStringOrPattern finds the matches of `pattern` in `subject`, each along with its replacement expanded from `replace`
 func FindStringOrPattern(pattern *regexp.Regexp, replace, subject string, bytesInDiff int) []MatchString {
-	allIndexes := pattern.FindAllStringIndex(subject, -1)
+	allIndexes := pattern.FindAllStringSubmatchIndex(subject, -1)
 
 	matches := make([]MatchString, 0)
 
@@ -66,11 +165,12 @@ func FindStringOrPattern(pattern *regexp.Regexp, replace, subject string, bytesI
 
 		matches = append(matches, MatchString{
 			Search:     string(matchString),
-			Replace:    replace,
+			Replace:    string(pattern.ExpandString(nil, replace, subject, indexes)),
 			Before:     string(subjectSlice[leftmostIndex:indexes[0]]),
 			After:      string(subjectSlice[indexes[1]:rightmostIndex]),
 			IndexStart: indexes[0],
 			IndexEnd:   indexes[1],
+			submatches: indexes,
 		})
 	}
 
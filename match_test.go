@@ -1,9 +1,15 @@
 package fds
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+
+	"github.com/fatih/color"
 )
 
 func TestFindStringOrPattern(t *testing.T) {
@@ -36,6 +42,7 @@ func TestFindStringOrPattern(t *testing.T) {
 					After:      "",
 					IndexStart: 0,
 					IndexEnd:   4,
+					submatches: []int{0, 4},
 				},
 			},
 		},
@@ -53,6 +60,7 @@ func TestFindStringOrPattern(t *testing.T) {
 					After:      " and that's it",
 					IndexStart: 13,
 					IndexEnd:   17,
+					submatches: []int{13, 17},
 				},
 			},
 		},
@@ -70,6 +78,7 @@ func TestFindStringOrPattern(t *testing.T) {
 					After:      ", and now I ran out ",
 					IndexStart: 32,
 					IndexEnd:   36,
+					submatches: []int{32, 36},
 				},
 			},
 		},
@@ -87,6 +96,7 @@ func TestFindStringOrPattern(t *testing.T) {
 					After:      " some random 20-char",
 					IndexStart: 20,
 					IndexEnd:   24,
+					submatches: []int{20, 24},
 				},
 			},
 		},
@@ -99,11 +109,12 @@ func TestFindStringOrPattern(t *testing.T) {
 			want: []MatchString{
 				{
 					Search:     "text",
-					Replace:    "$1",
+					Replace:    "",
 					Before:     "some random 20-char ",
 					After:      " some random 20-char",
 					IndexStart: 20,
 					IndexEnd:   24,
+					submatches: []int{20, 24},
 				},
 			},
 		},
@@ -116,11 +127,12 @@ func TestFindStringOrPattern(t *testing.T) {
 			want: []MatchString{
 				{
 					Search:     "text",
-					Replace:    "other $1",
+					Replace:    "other text",
 					Before:     "some random 20-char ",
 					After:      " some random 20-char",
 					IndexStart: 20,
 					IndexEnd:   24,
+					submatches: []int{20, 24, 20, 24},
 				},
 			},
 		},
@@ -138,6 +150,7 @@ func TestFindStringOrPattern(t *testing.T) {
 					After:      " some random 20-char",
 					IndexStart: 20,
 					IndexEnd:   24,
+					submatches: []int{20, 24},
 				},
 			},
 		},
@@ -155,6 +168,7 @@ func TestFindStringOrPattern(t *testing.T) {
 					After:      " some random 20-char",
 					IndexStart: 20,
 					IndexEnd:   24,
+					submatches: []int{20, 24},
 				},
 			},
 		},
@@ -172,6 +186,7 @@ func TestFindStringOrPattern(t *testing.T) {
 					After:      ", this is another te",
 					IndexStart: 12,
 					IndexEnd:   16,
+					submatches: []int{12, 16},
 				},
 				{
 					Search:     "text",
@@ -180,6 +195,7 @@ func TestFindStringOrPattern(t *testing.T) {
 					After:      ", this is yet anothe",
 					IndexStart: 34,
 					IndexEnd:   38,
+					submatches: []int{34, 38},
 				},
 				{
 					Search:     "text",
@@ -188,6 +204,7 @@ func TestFindStringOrPattern(t *testing.T) {
 					After:      "",
 					IndexStart: 60,
 					IndexEnd:   64,
+					submatches: []int{60, 64},
 				},
 			},
 		},
@@ -203,3 +220,100 @@ func TestFindStringOrPattern(t *testing.T) {
 		})
 	}
 }
+
+func TestConfirmMatch(t *testing.T) {
+	match := MatchString{Search: "foo", Replace: "bar", Before: "a ", After: " b"}
+
+	tests := []struct {
+		name   string
+		editor string
+		input  string
+		want   Answer
+		output string
+	}{
+		{name: "Yes", input: "y\n", want: Answer{Choice: ConfirmYes}},
+		{name: "All in file", input: "A\n", want: Answer{Choice: ConfirmAllInFile}},
+		{name: "Help, then no", input: "?\nn\n", want: Answer{Choice: ConfirmNo}, output: "u - undo the previous answer in this file"},
+		{name: "Edit typed in", input: "e\nbaz qux\n", want: Answer{Choice: ConfirmEdit, Replacement: "baz qux"}},
+		{name: "Edit in $EDITOR", editor: "sed -i s/bar/baz/", input: "e\n", want: Answer{Choice: ConfirmEdit, Replacement: "baz"}},
+		{name: "Jump", input: "/\n^import\n", want: Answer{Choice: ConfirmJump, Pattern: "^import"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("EDITOR", tc.editor)
+
+			var stdout bytes.Buffer
+
+			prompt := Prompt{File: "input", LineNumber: 3, Match: &match, ContextBefore: []string{"one", "two"}, ContextAfter: []string{"four"}}
+			result, err := ConfirmMatch(prompt, strings.NewReader(tc.input), &stdout)
+
+			if err != nil {
+				t.Fatalf("ConfirmMatch() returned an unexpected error '%s'", err)
+			}
+
+			if result != tc.want {
+				t.Errorf("ConfirmMatch() = %+v, want %+v", result, tc.want)
+			}
+
+			for _, want := range []string{"1\tone\n", "2\ttwo\n", "4\tfour\n", tc.output} {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("ConfirmMatch() printed %q, want it to contain %q", stdout.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfirmMatch_EditorOnPromptStreams(t *testing.T) {
+	// the editor is a program the OS runs, on a temporary file of its own
+	editor := filepath.Join(t.TempDir(), "editor.sh")
+
+	if err := os.WriteFile(editor, []byte("#!/bin/sh\necho editing\nsed -i s/bar/baz/ \"$1\"\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write editor: %s", err)
+	}
+
+	t.Setenv("EDITOR", editor)
+
+	var stdout bytes.Buffer
+
+	match := MatchString{Search: "foo", Replace: "bar"}
+	result, err := ConfirmMatch(Prompt{File: "input", LineNumber: 1, Match: &match}, strings.NewReader("e\n"), &stdout)
+
+	if err != nil || result.Replacement != "baz" {
+		t.Fatalf("ConfirmMatch() = %+v, %v, want the replacement edited", result, err)
+	}
+
+	// the editor shows on the prompts, not on the output of the command
+	if !strings.Contains(stdout.String(), "editing\n") {
+		t.Errorf("ConfirmMatch() printed %q, want it to contain the output of the editor", stdout.String())
+	}
+}
+
+func TestHighlightGroups(t *testing.T) {
+	text, group := color.New(color.FgRed), color.New(color.Underline)
+	text.EnableColor()
+	group.EnableColor()
+
+	tests := []struct {
+		name    string
+		pattern *regexp.Regexp
+		subject string
+		want    string
+	}{
+		{name: "No groups", pattern: regexp.MustCompile("text"), subject: "a text", want: text.Sprint("text")},
+		{name: "Group inside the match", pattern: regexp.MustCompile(`(\w+)_id`), subject: "a user_id", want: group.Sprint("user") + text.Sprint("_id")},
+		{name: "Nested and unmatched groups", pattern: regexp.MustCompile(`a((b)c)(x)?d`), subject: "- abcd", want: text.Sprint("a") + group.Sprint("bc") + text.Sprint("d")},
+		{name: "Group around the match", pattern: regexp.MustCompile(`(t)(ext)`), subject: "text", want: group.Sprint("text")},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			match := FindStringOrPattern(tc.pattern, "", tc.subject, 20)[0]
+
+			if result := highlightGroups(match, text, group); result != tc.want {
+				t.Errorf("highlightGroups() = %q, want %q", result, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package fds
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+/**
+ * LineOperations are performed on whole lines matching the search pattern, instead of substituting the match.
+ * Deleting a line and inserting text around it at the same time replaces the line with the inserted text
+ */
+type LineOperations struct {
+	Delete       bool
+	InsertBefore string
+	InsertAfter  string
+}
+
+func (o LineOperations) IsSet() bool {
+	return o.Delete || o.InsertBefore != "" || o.InsertAfter != ""
+}
+
+// Apply returns the lines written in place of `line`, which may be none when it's deleted
+func (o LineOperations) Apply(line string) []string {
+	var lines []string
+
+	if o.InsertBefore != "" {
+		lines = append(lines, o.InsertBefore)
+	}
+
+	if !o.Delete {
+		lines = append(lines, line)
+	}
+
+	if o.InsertAfter != "" {
+		lines = append(lines, o.InsertAfter)
+	}
+
+	return lines
+}
+
+func (o LineOperations) String() string {
+	var operations []string
+
+	if o.InsertBefore != "" {
+		operations = append(operations, fmt.Sprintf("insert %q before", o.InsertBefore))
+	}
+
+	if o.Delete {
+		operations = append(operations, "delete")
+	}
+
+	if o.InsertAfter != "" {
+		operations = append(operations, fmt.Sprintf("insert %q after", o.InsertAfter))
+	}
+
+	return strings.Join(operations, ", ")
+}
+
+func ConfirmLineOperation(operations LineOperations, line string, filename string, lineNumber int, stdin io.Reader, stdout io.Writer) (rune, error) {
+	red := color.New(color.FgHiRed, color.Bold, color.Italic)
+	green := color.New(color.FgHiGreen, color.Bold)
+
+	fmt.Fprintf(stdout, "File\t%s\n", filename)
+
+	if operations.InsertBefore != "" {
+		fmt.Fprintf(stdout, "\t%s\n", green.Sprint("+ "+operations.InsertBefore))
+	}
+
+	if operations.Delete {
+		fmt.Fprintf(stdout, "%d\t%s\n", lineNumber, red.Sprint("- "+line))
+	} else {
+		fmt.Fprintf(stdout, "%d\t  %s\n", lineNumber, line)
+	}
+
+	if operations.InsertAfter != "" {
+		fmt.Fprintf(stdout, "\t%s\n", green.Sprint("+ "+operations.InsertAfter))
+	}
+
+	confirmText := "[y]es [n]o [a]ll q[uit]"
+	valid := []rune{'y', 'n', 'a', 'q'}
+	ret, err := Confirm(stdin, stdout, confirmText, valid)
+
+	if err != nil {
+		return 0, err
+	}
+
+	fmt.Fprintln(stdout)
+
+	return ret, nil
+}
@@ -0,0 +1,48 @@
+package fds
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLineOperations_Apply(t *testing.T) {
+	var tests = []struct {
+		name       string
+		operations LineOperations
+		line       string
+		want       []string
+	}{
+		{
+			name:       "delete",
+			operations: LineOperations{Delete: true},
+			line:       "foo",
+			want:       nil,
+		},
+		{
+			name:       "insert before",
+			operations: LineOperations{InsertBefore: "bar"},
+			line:       "foo",
+			want:       []string{"bar", "foo"},
+		},
+		{
+			name:       "insert after",
+			operations: LineOperations{InsertAfter: "bar"},
+			line:       "foo",
+			want:       []string{"foo", "bar"},
+		},
+		{
+			name:       "delete and insert replaces line",
+			operations: LineOperations{Delete: true, InsertBefore: "bar", InsertAfter: "baz"},
+			line:       "foo",
+			want:       []string{"bar", "baz"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := tc.operations.Apply(tc.line); !slices.Equal(result, tc.want) {
+				t.Errorf("LineOperations.Apply(%q) = %q, want %q", tc.line, result, tc.want)
+			}
+		})
+	}
+}
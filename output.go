@@ -0,0 +1,80 @@
+package fds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OutputFormat is the format the outcome of replacing in files is printed in, once they are all done
+type OutputFormat string
+
+const (
+	// OutputText prints nothing, the outcome being told by the exit status and the errors printed on stderr
+	OutputText OutputFormat = "text"
+
+	// OutputJSON prints the Report as a JSON object, the errors of the files included
+	OutputJSON OutputFormat = "json"
+)
+
+func (f *OutputFormat) String() string {
+	return string(*f)
+}
+
+func (f *OutputFormat) Type() string {
+	return "text|json"
+}
+
+func (f *OutputFormat) Set(value string) error {
+	switch format := OutputFormat(strings.ToLower(value)); format {
+	case OutputText, OutputJSON:
+		*f = format
+	default:
+		return fmt.Errorf("%q is not a valid output format. Use text or json", value)
+	}
+
+	return nil
+}
+
+// UnmarshalText lets output formats be read from configuration files, validated as they are on the command line
+func (f *OutputFormat) UnmarshalText(text []byte) error {
+	return f.Set(string(text))
+}
+
+type jsonFileReport struct {
+	Path         string `json:"path"`
+	Replaced     bool   `json:"replaced"`
+	Replacements int    `json:"replacements"`
+	Error        string `json:"error,omitempty"`
+}
+
+type jsonReport struct {
+	Files        []jsonFileReport `json:"files"`
+	Replacements int              `json:"replacements"`
+}
+
+/**
+ * WriteReport writes `report` into `writer` in `format`. In OutputJSON, it is a single object, such as:
+ *
+ *	{"files":[{"path":"a.go","replaced":true,"replacements":2}],"replacements":2}
+ */
+func WriteReport(writer io.Writer, report Report, format OutputFormat) error {
+	if format != OutputJSON {
+		return nil
+	}
+
+	output := jsonReport{Files: []jsonFileReport{}, Replacements: report.Replacements}
+
+	for _, file := range report.Files {
+		fileReport := jsonFileReport{Path: file.Path, Replaced: file.Replaced, Replacements: file.Replacements}
+
+		if file.Err != nil {
+			fileReport.Error = file.Err.Error()
+		}
+
+		output.Files = append(output.Files, fileReport)
+	}
+
+	return json.NewEncoder(writer).Encode(output)
+}
@@ -0,0 +1,57 @@
+package fds
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWriteReport(t *testing.T) {
+	report := newReport([]FileReport{
+		{Path: "a.go", Replaced: true, Replacements: 2},
+		{Path: "b.go", Err: errors.New("permission denied")},
+	})
+
+	var tests = []struct {
+		name   string
+		report Report
+		format OutputFormat
+		want   string
+	}{
+		{name: "text", report: report, format: OutputText, want: ""},
+		{name: "unset", report: report, want: ""},
+		{
+			name:   "json",
+			report: report,
+			format: OutputJSON,
+			want:   `{"files":[{"path":"a.go","replaced":true,"replacements":2},{"path":"b.go","replaced":false,"replacements":0,"error":"permission denied"}],"replacements":2}` + "\n",
+		},
+		{name: "json without files", format: OutputJSON, want: `{"files":[],"replacements":0}` + "\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var output bytes.Buffer
+
+			if err := WriteReport(&output, tc.report, tc.format); err != nil {
+				t.Fatalf("WriteReport() returned an unexpected error '%s'", err)
+			}
+
+			if output.String() != tc.want {
+				t.Errorf("WriteReport() wrote %q, want %q", output.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestOutputFormat_Set(t *testing.T) {
+	var format OutputFormat
+
+	if err := format.Set("JSON"); err != nil || format != OutputJSON {
+		t.Errorf("Set(%q) = %v, set %q, want %q", "JSON", err, format, OutputJSON)
+	}
+
+	if err := format.Set("xml"); err == nil {
+		t.Errorf("Set(%q) returned no error, want one", "xml")
+	}
+}
@@ -0,0 +1,124 @@
+package fds
+
+import (
+	"bytes"
+	"regexp/syntax"
+	"slices"
+	"unicode"
+)
+
+// maxPrefilterLiterals bounds how many alternatives a prefilter looks for, past which it is not worth scanning for them
+const maxPrefilterLiterals = 16
+
+/**
+ * prefilter holds literals out of which at least one is found in any text the pattern matches. Content holding none
+ * of them has no match, and is not worth splitting into lines. A prefilter with no literals lets everything through
+ */
+type prefilter struct {
+	literals [][]byte
+}
+
+func newPrefilter(pattern string) prefilter {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+
+	if err != nil {
+		return prefilter{}
+	}
+
+	var filter prefilter
+
+	for _, literal := range requiredLiterals(re.Simplify()) {
+		filter.literals = append(filter.literals, []byte(literal))
+	}
+
+	return filter
+}
+
+// MayMatch tells whether `content` holds any of the literals, in which case it may hold a match
+func (p prefilter) MayMatch(content []byte) bool {
+	if len(p.literals) == 0 {
+		return true
+	}
+
+	for _, literal := range p.literals {
+		if bytes.Contains(content, literal) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * requiredLiterals returns the literals out of which at least one is part of any match of `re`, or nil when no
+ * such literals are known. Case-insensitive literals are only kept when none of their characters has another case
+ */
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 && slices.ContainsFunc(re.Rune, hasOtherCase) {
+			return nil
+		}
+
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return nil
+		}
+
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpConcat:
+		return requiredInConcat(re.Sub)
+	case syntax.OpAlternate:
+		var literals []string
+
+		for _, sub := range re.Sub {
+			subLiterals := requiredLiterals(sub)
+
+			if subLiterals == nil {
+				return nil
+			}
+
+			literals = append(literals, subLiterals...)
+		}
+
+		if len(literals) > maxPrefilterLiterals {
+			return nil
+		}
+
+		return literals
+	}
+
+	return nil
+}
+
+// requiredInConcat picks, out of the parts of a concatenation, the ones whose shortest literal is the longest
+func requiredInConcat(subs []*syntax.Regexp) []string {
+	var best []string
+
+	for _, sub := range subs {
+		literals := requiredLiterals(sub)
+
+		if literals != nil && (best == nil || shortestLength(literals) > shortestLength(best)) {
+			best = literals
+		}
+	}
+
+	return best
+}
+
+func shortestLength(literals []string) int {
+	shortest := len(literals[0])
+
+	for _, literal := range literals[1:] {
+		shortest = min(shortest, len(literal))
+	}
+
+	return shortest
+}
+
+func hasOtherCase(char rune) bool {
+	return unicode.SimpleFold(char) != char
+}
@@ -0,0 +1,73 @@
+package fds
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewPrefilter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "foo", want: []string{"foo"}},
+		{pattern: `^foo\d+bar$`, want: []string{"foo"}},
+		{pattern: `a+ lorem (ipsum)+`, want: []string{" lorem "}},
+		{pattern: `(foo|barbaz)\s`, want: []string{"foo", "barbaz"}},
+		{pattern: `\.go$`, want: []string{".go"}},
+		{pattern: `(?i)1\.0`, want: []string{"1.0"}},
+		{pattern: `(?i)foo`, want: nil},
+		{pattern: `foo|.*`, want: nil},
+		{pattern: `(foo)?bar*`, want: []string{"ba"}},
+		{pattern: `\w+`, want: nil},
+		{pattern: `(`, want: nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern, func(t *testing.T) {
+			var result []string
+
+			for _, literal := range newPrefilter(tc.pattern).literals {
+				result = append(result, string(literal))
+			}
+
+			if !reflect.DeepEqual(result, tc.want) {
+				t.Errorf("newPrefilter(%q) = %q, want %q", tc.pattern, result, tc.want)
+			}
+		})
+	}
+}
+
+func TestPrefilter_MayMatch(t *testing.T) {
+	contents := []string{
+		"",
+		"foo",
+		"lorem ipsum\ndolor foo1bar",
+		"FOO bar\r\nbaz",
+		"main.go\nmain_test.go",
+		"mamãe MAMÃE",
+		"a lorem ipsumipsum",
+		"barbaz\tfoo",
+	}
+
+	patterns := []string{"foo", `^foo\d+bar$`, `a+ lorem (ipsum)+`, `(foo|barbaz)\s`, `\.go$`, `(?i)foo`, `(?i)mamãe`, "baz$", `(foo)?bar*`}
+
+	for _, pattern := range patterns {
+		re := regexp.MustCompile(pattern)
+		filter := newPrefilter(pattern)
+
+		for _, content := range contents {
+			var matches bool
+
+			for _, line := range strings.FieldsFunc(content, func(r rune) bool { return r == '\n' || r == '\r' }) {
+				matches = matches || re.MatchString(line)
+			}
+
+			if matches && !filter.MayMatch([]byte(content)) {
+				t.Errorf("prefilter of %q rejected %q, which matches", pattern, content)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package fds
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	progressBarWidth      = 20
+	progressFileWidth     = 40
+	progressRedrawEvery   = 100 * time.Millisecond
+	progressTickEvery     = time.Second
+	progressClearLine     = "\r\x1b[K"
+	progressEllipsis      = "…"
+	progressUnknownETA    = "--"
+	progressETAResolution = time.Second
+)
+
+/**
+ * TerminalProgress displays how far along a run is on a single line of a terminal, redrawn as files are done, and every
+ * second in between, so that the estimate keeps up while a long file is replaced in: the files done out of the ones
+ * found so far, the replacements performed, an estimate of the time left and the file last started. As directories are
+ * walked while files are replaced in, the estimate only accounts for files found
+ */
+type TerminalProgress struct {
+	output io.Writer
+	now    func() time.Time
+
+	// tick is how often the line is redrawn without any update, from the first update until Finish
+	tick time.Duration
+
+	// mutex serialises the updates with the redraws of the ticker
+	mutex          sync.Mutex
+	stop, finished chan struct{}
+
+	started, drawn time.Time
+	found, done    int
+	replacements   int
+	current        string
+}
+
+func NewTerminalProgress(output io.Writer) *TerminalProgress {
+	return &TerminalProgress{output: output, now: time.Now, tick: progressTickEvery}
+}
+
+// Update is a ProgressFunc, redrawing the line at most every 100ms
+func (p *TerminalProgress) Update(progress Progress) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.started.IsZero() {
+		p.started = p.now()
+		p.stop, p.finished = make(chan struct{}), make(chan struct{})
+
+		go p.redrawOnTicks(p.stop, p.finished)
+	}
+
+	switch progress.Stage {
+	case FileQueued:
+		p.found++
+	case FileStarted:
+		p.current = progress.File.Path
+	case FileDone:
+		p.done++
+		p.replacements += progress.File.Replacements
+	}
+
+	p.redraw()
+}
+
+func (p *TerminalProgress) redraw() {
+	if now := p.now(); now.Sub(p.drawn) >= progressRedrawEvery {
+		p.drawn = now
+		fmt.Fprint(p.output, progressClearLine+p.String())
+	}
+}
+
+func (p *TerminalProgress) redrawOnTicks(stop <-chan struct{}, finished chan<- struct{}) {
+	defer close(finished)
+
+	ticker := time.NewTicker(p.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.mutex.Lock()
+			p.redraw()
+			p.mutex.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Finish stops redrawing and clears the line, so that whatever is printed next starts on a clean one
+func (p *TerminalProgress) Finish() {
+	p.mutex.Lock()
+	stop, finished := p.stop, p.finished
+	p.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-finished
+	}
+
+	if !p.drawn.IsZero() {
+		fmt.Fprint(p.output, progressClearLine)
+	}
+}
+
+func (p *TerminalProgress) String() string {
+	filled := 0
+
+	if p.found > 0 {
+		filled = p.done * progressBarWidth / p.found
+	}
+
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled)
+
+	return fmt.Sprintf("[%s] %d/%d files, %d replacements, ETA %s %s",
+		bar, p.done, p.found, p.replacements, p.eta(), shortenPath(p.current, progressFileWidth))
+}
+
+// eta estimates the time left from the pace files were done at so far
+func (p *TerminalProgress) eta() string {
+	if p.done == 0 {
+		return progressUnknownETA
+	}
+
+	elapsed := p.now().Sub(p.started)
+	left := elapsed * time.Duration(p.found-p.done) / time.Duration(p.done)
+
+	return left.Round(progressETAResolution).String()
+}
+
+// shortenPath keeps the end of `path`, which tells the file apart, when it is longer than `width` characters
+func shortenPath(path string, width int) string {
+	runes := []rune(path)
+
+	if len(runes) <= width {
+		return path
+	}
+
+	return progressEllipsis + string(runes[len(runes)-width+1:])
+}
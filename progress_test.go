@@ -0,0 +1,106 @@
+package fds
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTerminalProgress(t *testing.T) {
+	var output bytes.Buffer
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	progress := NewTerminalProgress(&output)
+	progress.now = func() time.Time { return clock }
+
+	for _, path := range []string{"a", "b", "c", "d"} {
+		progress.Update(Progress{Stage: FileQueued, File: FileReport{Path: path}})
+	}
+
+	progress.Update(Progress{Stage: FileStarted, File: FileReport{Path: "a"}})
+
+	clock = clock.Add(2 * time.Second)
+	progress.Update(Progress{Stage: FileDone, File: FileReport{Path: "a", Replaced: true, Replacements: 3}})
+	progress.Update(Progress{Stage: FileStarted, File: FileReport{Path: "b"}})
+
+	if want := "[#####...............] 1/4 files, 3 replacements, ETA 6s b"; progress.String() != want {
+		t.Errorf("TerminalProgress.String() = %q, want %q", progress.String(), want)
+	}
+
+	// the last update came within 100ms of the previous one, so only two lines were drawn
+	if count := strings.Count(output.String(), progressClearLine); count != 2 {
+		t.Errorf("TerminalProgress drew %d lines, want 2", count)
+	}
+
+	progress.Finish()
+
+	if !strings.HasSuffix(output.String(), progressClearLine) {
+		t.Errorf("TerminalProgress.Finish() did not clear the line, output %q", output.String())
+	}
+}
+
+func TestTerminalProgress_RedrawsWithoutUpdates(t *testing.T) {
+	var output bytes.Buffer
+	var elapsed atomic.Int64
+
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	progress := NewTerminalProgress(&output)
+	progress.now = func() time.Time { return started.Add(time.Duration(elapsed.Load())) }
+	progress.tick = time.Millisecond
+
+	for _, path := range []string{"a", "b"} {
+		progress.Update(Progress{Stage: FileQueued, File: FileReport{Path: path}})
+	}
+
+	elapsed.Store(int64(time.Second))
+	progress.Update(Progress{Stage: FileDone, File: FileReport{Path: "a"}})
+	progress.Update(Progress{Stage: FileStarted, File: FileReport{Path: "b"}})
+
+	// b takes long, during which the estimate keeps growing with the time elapsed
+	elapsed.Store(int64(5 * time.Second))
+	time.Sleep(50 * time.Millisecond)
+	progress.Finish()
+
+	if want := "[##########..........] 1/2 files, 0 replacements, ETA 5s b"; !strings.Contains(output.String(), want) {
+		t.Errorf("TerminalProgress drew %q, want it redrawn as %q", output.String(), want)
+	}
+
+	// nothing is drawn once finished
+	drawn := output.Len()
+	time.Sleep(10 * time.Millisecond)
+
+	if output.Len() != drawn {
+		t.Errorf("TerminalProgress drew %q after Finish(), want nothing", output.String()[drawn:])
+	}
+}
+
+func TestTerminalProgress_FinishWithoutUpdates(t *testing.T) {
+	var output bytes.Buffer
+
+	NewTerminalProgress(&output).Finish()
+
+	if output.Len() > 0 {
+		t.Errorf("TerminalProgress.Finish() printed %q, want nothing", output.String())
+	}
+}
+
+func TestShortenPath(t *testing.T) {
+	tests := []struct {
+		path  string
+		width int
+		want  string
+	}{
+		{path: "src/main.go", width: 20, want: "src/main.go"},
+		{path: "src/main.go", width: 11, want: "src/main.go"},
+		{path: "src/main.go", width: 8, want: "…main.go"},
+		{path: "mamãe/filho.go", width: 10, want: "…/filho.go"},
+	}
+
+	for _, tc := range tests {
+		if result := shortenPath(tc.path, tc.width); result != tc.want {
+			t.Errorf("shortenPath(%q, %d) = %q, want %q", tc.path, tc.width, result, tc.want)
+		}
+	}
+}
@@ -1,11 +1,11 @@
 package fds
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
 	"path/filepath"
 )
 
@@ -16,105 +16,249 @@ const (
 	ConfirmNo   = 'n'
 	ConfirmAll  = 'a'
 	ConfirmQuit = 'q'
+
+	// answers to the prompts of matches only, which apply to the file being replaced in
+	ConfirmAllInFile = 'A'
+	ConfirmSkipFile  = 'd'
+	ConfirmEdit      = 'e'
+	ConfirmUndo      = 'u'
+	ConfirmJump      = '/'
+	ConfirmMatchHelp = '?'
 )
 
 type FileReplacer struct {
 	LineReplacer
 
-	config        Config
 	inputFilePath string
+	confirm       ConfirmFunc
+	prefilter     prefilter
 }
 
 func NewFileReplacer(inputFilePath, search, replace string, config Config) FileReplacer {
 	replacer := FileReplacer{
-		LineReplacer:  LineReplacer{flags: config.Flags, replace: replace, search: search},
+		LineReplacer:  LineReplacer{config: config, replace: replace, search: search, budget: newReplaceBudget(config.Limits, config.replaced)},
 		inputFilePath: inputFilePath,
-		config:        config,
 	}
 	replacer.searchRegexp = replacer.compilePattern(search)
+	replacer.prefilter = newPrefilter(replacer.searchRegexp.String())
 
 	return replacer
 }
 
-func (r FileReplacer) Replace(stdin io.Reader, stdout io.Writer, confirmAnswer *ConfirmAnswer) (outputFile *os.File, err error) {
-	if r.flags["confirm"] {
-		outputFile, err = r.replaceInteractive(stdin, stdout, confirmAnswer)
+// WithConfirm returns a copy of the replacer that asks `confirm` instead of prompting on stdin and stdout
+func (r FileReplacer) WithConfirm(confirm ConfirmFunc) FileReplacer {
+	r.confirm = confirm
+
+	return r
+}
+
+func (r FileReplacer) confirmFunc(stdin io.Reader, stdout io.Writer) ConfirmFunc {
+	if r.confirm != nil {
+		return r.confirm
+	}
+
+	return NewTerminalConfirm(stdin, stdout)
+}
+
+/**
+ * mayChange tells whether replacing in `content` may change it, so that files without a match are skipped before
+ * being split into lines. Files are never skipped when line endings are normalised, as that changes them regardless
+ */
+func (r FileReplacer) mayChange(content []byte) bool {
+	return r.config.LineEnding != "" || r.prefilter.MayMatch(content)
+}
+
+// replacements tells how many replacements, or line operations, were performed in the file so far
+func (r FileReplacer) replacements() int {
+	return r.budget.file
+}
+
+/**
+ * Replace writes the result of replacing in the file into a temporary file, returned closed and only when something
+ * changed. It is up to the caller to rename or remove it.
+ * Once `ctx` is cancelled, it stops reading the file and returns an interrupted error, leaving no temporary file behind
+ */
+func (r FileReplacer) Replace(ctx context.Context, stdin io.Reader, stdout io.Writer, confirmAnswer *ConfirmAnswer) (outputFile TempFile, err error) {
+	if r.config.Confirm {
+		outputFile, err = r.replaceInteractive(ctx, stdin, stdout, confirmAnswer)
 
 		return
 	}
 
-	return r.replaceAll()
+	return r.replaceAll(ctx)
 }
 
-func (r FileReplacer) replaceAll() (tmpFile *os.File, err error) {
+func (r FileReplacer) replaceAll(ctx context.Context) (tmpFile TempFile, err error) {
 	var fileChanged bool
+	var lineNumber int
 
-	inputFile, err := openInputFile(r.inputFilePath)
+	inputFile, err := openInputFile(r.config.fileSystem(), r.inputFilePath)
 
 	if err != nil {
-		return nil, NewFileReadError(r.inputFilePath)
+		return nil, NewFileReadError(r.inputFilePath, err)
 	}
 
+	defer inputFile.Close()
+
 	if inputFileStat, _ := inputFile.Stat(); inputFileStat.Size() == 0 {
 		return
 	}
 
-	reader := bufio.NewReader(inputFile)
+	decodedInput, inputEncoding, err := decodeInput(inputFile, r.config.Encoding)
+
+	if err != nil {
+		return nil, NewFileReadError(r.inputFilePath, err)
+	}
+
+	if !inputEncoding.isUTF8() {
+		r.config.logf("File %s is encoded in %s", r.inputFilePath, inputEncoding)
+	}
+
+	if !r.mayChange(decodedInput) {
+		return nil, nil
+	}
+
+	reader := newLineReader(bytes.NewReader(decodedInput))
 
 	buffer := &bytes.Buffer{}
-	writer := bufio.NewWriter(buffer)
+	writer := newLineWriter(buffer, r.config.LineEnding)
+	selector := r.config.Selection.newLineSelector()
 
 	for {
-		line, err := reader.ReadString('\n')
+		if err := ctx.Err(); err != nil {
+			return nil, NewInterruptedError(err)
+		}
+
+		line, lineEnding, err := reader.ReadLine()
+		lineNumber++
 
 		if err != nil && err != io.EOF {
-			return nil, NewFileReadError(r.inputFilePath)
+			return nil, NewFileReadError(r.inputFilePath, err)
 		}
 
-		replacedLine, lineChanged := r.LineReplacer.Replace(line)
+		if err == io.EOF && line == "" {
+			break
+		}
+
+		replacedLines, lineChanged := []string{line}, false
+
+		if selector.Selects(lineNumber, line) {
+			replacedLines, lineChanged = r.replaceLine(line, lineNumber)
+		}
 
 		if lineChanged {
 			fileChanged = true
 		}
 
-		_, errWrite := writer.WriteString(replacedLine)
+		errWrite := writer.WriteLines(replacedLines, lineEnding)
 
 		if errWrite != nil {
-			return nil, fmt.Errorf("Error while writing temporary file: %s", err)
+			return nil, fmt.Errorf("Error while writing temporary file: %w", errWrite)
 		}
 
-		if err != nil && err == io.EOF {
+		if err == io.EOF {
 			break
 		}
 	}
 
 	writer.Flush()
+	fileChanged = fileChanged || writer.normalised
 
 	if fileChanged {
-		tmpFile, err = os.CreateTemp("", filepath.Base(inputFile.Name()))
+		var encoded []byte
+
+		encoded, err = inputEncoding.encode(buffer.Bytes())
 
 		if err != nil {
-			return nil, NewTempFileWriteError(filepath.Base(inputFile.Name()))
+			return nil, NewFileEncodeError(r.inputFilePath, inputEncoding.String(), err)
 		}
 
-		_, err = io.Copy(tmpFile, buffer)
+		return r.writeTempFile(encoded)
+	}
 
-		if err != nil {
-			return nil, NewFileWriteError(inputFile.Name())
-		}
+	return nil, nil
+}
+
+/**
+ * writeTempFile writes `content` into a new temporary file, closed once written so that it can be renamed.
+ * On failure, nothing is left behind
+ */
+func (r FileReplacer) writeTempFile(content []byte) (TempFile, error) {
+	fileSystem := r.config.fileSystem()
+	pattern := filepath.Base(r.inputFilePath)
+
+	tmpFile, err := fileSystem.CreateTemp("", pattern)
+
+	if err != nil {
+		return nil, NewTempFileWriteError(pattern, err)
+	}
+
+	_, err = tmpFile.Write(content)
+
+	if err != nil {
+		discardTempFile(fileSystem, tmpFile)
+
+		return nil, NewTempFileWriteError(filepath.Dir(tmpFile.Name()), err)
 	}
 
-	return tmpFile, err
+	if err = tmpFile.Close(); err != nil {
+		fileSystem.Remove(tmpFile.Name())
+
+		return nil, NewTempFileWriteError(filepath.Dir(tmpFile.Name()), err)
+	}
+
+	return tmpFile, nil
 }
 
-func openInputFile(path string) (*os.File, error) {
-	fileStat, _ := os.Lstat(path)
+/**
+ * replaceLine either substitutes the matches in `line` or, when line operations are supplied, performs them on it.
+ * It returns the lines written in place of `line`
+ */
+func (r FileReplacer) replaceLine(line string, lineNumber int) ([]string, bool) {
+	if !r.config.Operations.IsSet() && r.config.Verbose {
+		return r.replaceLogged(line, lineNumber)
+	}
+
+	if !r.config.Operations.IsSet() {
+		replacedLine, lineChanged := r.LineReplacer.Replace(line)
+
+		return []string{replacedLine}, lineChanged
+	}
+
+	if !r.searchRegexp.MatchString(line) || !r.budget.take() {
+		return []string{line}, false
+	}
+
+	r.config.logf("Performing %s on line %d of file %s", r.config.Operations, lineNumber, r.inputFilePath)
+
+	return r.config.Operations.Apply(line), true
+}
+
+func openInputFile(fileSystem FileSystem, path string) (fs.File, error) {
+	fileStat, err := fileSystem.Lstat(path)
+
+	if err != nil {
+		return nil, err
+	}
+
 	inputFilePath := path
 
-	if fileStat.Mode().Type() == os.ModeSymlink.Type() {
-		inputFilePath, _ = filepath.EvalSymlinks(path)
-		inputFilePath, _ = filepath.Abs(inputFilePath)
+	if fileStat.Mode().Type() == fs.ModeSymlink {
+		inputFilePath, _ = fileSystem.EvalSymlinks(path)
+	}
+
+	return fileSystem.Open(inputFilePath)
+}
+
+// replaceLogged substitutes the matches in `line` as replaceLine does, logging each one along with its expanded replacement
+func (r FileReplacer) replaceLogged(line string, lineNumber int) ([]string, bool) {
+	segments := r.lineSegments(line)
+
+	for _, segment := range segments {
+		r.config.logf("Replacing %q on line %d of file %s with %q", line[segment.start:segment.end], lineNumber, r.inputFilePath, segment.text)
 	}
 
-	return os.OpenFile(inputFilePath, os.O_RDONLY, fileStat.Mode())
+	replacedLine := joinSegments(line, segments)
+
+	return []string{replacedLine}, replacedLine != line
 }
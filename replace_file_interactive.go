@@ -1,140 +1,435 @@
 package fds
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"regexp"
 )
 
-func (r FileReplacer) replaceInteractive(stdin io.Reader, stdout io.Writer, confirmAnswer *ConfirmAnswer) (outputFile *os.File, err error) {
-	var (
-		lineNumber                  int
-		tmpFile                     *os.File
-		confirmedAll, confirmedQuit bool
-		lineChanged                 bool
-		fileChanged                 bool
-	)
+// fileLine is a line of the file being replaced in, along with its line ending
+type fileLine struct {
+	text   string
+	ending LineEnding
+}
+
+// reviewedMatch is a match of the file, along with the answer given for it. Its Replace is the edited one, if edited
+type reviewedMatch struct {
+	MatchString
 
-	// feedback confirmedAll that propagated all the way back to the main function
-	confirmedAll = *confirmAnswer == ConfirmAll
+	// line is the index of the line of the match, among the lines of the file
+	line     int
+	accepted bool
+}
 
-	inputFile, err := openInputFile(r.inputFilePath)
+/**
+ * replaceInteractive reads the whole file before asking for its matches, one at a time, so that answers can be undone
+ * and matches jumped to. Only then are the accepted matches replaced
+ */
+func (r FileReplacer) replaceInteractive(ctx context.Context, stdin io.Reader, stdout io.Writer, confirmAnswer *ConfirmAnswer) (outputFile TempFile, err error) {
+	inputFile, err := openInputFile(r.config.fileSystem(), r.inputFilePath)
 
 	if err != nil {
-		return
+		return nil, NewFileReadError(r.inputFilePath, err)
 	}
 
+	defer inputFile.Close()
+
 	if inputFileStat, _ := inputFile.Stat(); inputFileStat.Size() == 0 {
 		return
 	}
 
-	reader := bufio.NewReader(inputFile)
+	decodedInput, inputEncoding, err := decodeInput(inputFile, r.config.Encoding)
+
+	if err != nil {
+		return nil, NewFileReadError(r.inputFilePath, err)
+	}
+
+	if !inputEncoding.isUTF8() {
+		r.config.logf("File %s is encoded in %s", r.inputFilePath, inputEncoding)
+	}
+
+	if !r.mayChange(decodedInput) {
+		return nil, nil
+	}
+
+	output, fileChanged, err := r.reviewContent(ctx, decodedInput, stdin, stdout, confirmAnswer)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if fileChanged {
+		var encoded []byte
+
+		encoded, err = inputEncoding.encode(output)
+
+		if err != nil {
+			return nil, NewFileEncodeError(r.inputFilePath, inputEncoding.String(), err)
+		}
+
+		return r.writeTempFile(encoded)
+	}
+
+	return nil, nil
+}
+
+/**
+ * reviewContent asks for the matches, or the line operations, of `content`, returning it with the accepted ones
+ * replaced, and whether that changed it
+ */
+func (r FileReplacer) reviewContent(ctx context.Context, content []byte, stdin io.Reader, stdout io.Writer, confirmAnswer *ConfirmAnswer) (output []byte, changed bool, err error) {
+	lines, err := readFileLines(bytes.NewReader(content))
+
+	if err != nil {
+		return nil, false, NewFileReadError(r.inputFilePath, err)
+	}
+
+	var written [][]string
+
+	confirm := r.confirmFunc(stdin, stdout)
+
+	if r.config.Operations.IsSet() {
+		written, changed, err = r.reviewOperations(ctx, lines, confirm, confirmAnswer)
+	} else {
+		written, changed, err = r.reviewMatches(ctx, lines, confirm, confirmAnswer)
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
 
 	buffer := &bytes.Buffer{}
-	writer := bufio.NewWriter(buffer)
+	writer := newLineWriter(buffer, r.config.LineEnding)
+
+	for i, line := range lines {
+		writer.WriteLines(written[i], line.ending)
+	}
+
+	writer.Flush()
+
+	return buffer.Bytes(), changed || writer.normalised, nil
+}
+
+func readFileLines(input io.Reader) ([]fileLine, error) {
+	var lines []fileLine
+
+	reader := newLineReader(input)
 
 	for {
-		line, err := reader.ReadString('\n')
-		lineNumber++
+		text, ending, err := reader.ReadLine()
 
 		if err != nil && err != io.EOF {
-			return nil, NewFileReadError(r.inputFilePath)
+			return nil, err
 		}
 
-		if confirmedAll {
-			line, lineChanged = r.LineReplacer.Replace(line)
+		if err == io.EOF && text == "" {
+			return lines, nil
 		}
 
-		if !confirmedAll && !confirmedQuit {
-			matches := FindStringOrPattern(r.searchRegexp, r.replace, line, 50)
+		lines = append(lines, fileLine{text: text, ending: ending})
 
-			line, lineChanged = r.confirmMatches(matches, line, lineNumber, stdin, stdout, confirmAnswer)
+		if err == io.EOF {
+			return lines, nil
 		}
+	}
+}
 
-		if lineChanged {
-			fileChanged = true
+// reviewOperations asks for the line operations of each selected line matching the pattern, returning the lines written in place of each line
+func (r FileReplacer) reviewOperations(ctx context.Context, lines []fileLine, confirm ConfirmFunc, confirmAnswer *ConfirmAnswer) (written [][]string, fileChanged bool, err error) {
+	selector := r.config.Selection.newLineSelector()
+	written = make([][]string, len(lines))
+
+	for i, line := range lines {
+		if err := ctx.Err(); err != nil {
+			return nil, false, NewInterruptedError(err)
 		}
 
-		writer.WriteString(line)
+		written[i] = []string{line.text}
 
-		if err != nil && err == io.EOF {
-			break
+		if !selector.Selects(i+1, line.text) {
+			continue
 		}
-	}
 
-	writer.Flush()
+		var lineChanged bool
 
-	if fileChanged {
-		directory := filepath.Base(inputFile.Name())
-		tmpFile, err = os.CreateTemp("", directory)
+		written[i], lineChanged, err = r.confirmOperations(line.text, i+1, confirm, confirmAnswer)
 
 		if err != nil {
-			return nil, NewTempFileWriteError(directory)
+			return nil, false, err
 		}
 
-		_, err = io.Copy(tmpFile, buffer)
+		fileChanged = fileChanged || lineChanged
+	}
 
-		if err != nil {
-			return nil, NewTempFileWriteError(directory)
+	return written, fileChanged, nil
+}
+
+// reviewMatches asks for each match of the selected lines, returning the lines written in place of each line
+func (r FileReplacer) reviewMatches(ctx context.Context, lines []fileLine, confirm ConfirmFunc, confirmAnswer *ConfirmAnswer) (written [][]string, fileChanged bool, err error) {
+	var matches []reviewedMatch
+
+	selector := r.config.Selection.newLineSelector()
+
+	for i, line := range lines {
+		if !selector.Selects(i+1, line.text) {
+			continue
+		}
+
+		for _, match := range FindStringOrPattern(r.searchRegexp, r.replace, line.text, 50) {
+			match.LineNumber = i + 1
+			match.Replace = r.expandReplacement(line.text, match.submatches)
+
+			r.config.logf("Found %q on line %d of file %s, to be replaced with %q", match.Search, match.LineNumber, r.inputFilePath, match.Replace)
+			matches = append(matches, reviewedMatch{MatchString: match, line: i})
 		}
 	}
 
-	return tmpFile, nil
-}
+	if err = r.review(ctx, matches, lines, confirm, confirmAnswer); err != nil {
+		return nil, false, err
+	}
 
-func (r FileReplacer) confirmMatches(matches []MatchString, line string, lineNumber int, stdin io.Reader, stdout io.Writer, confirmAnswer *ConfirmAnswer) (replacedLine string, lineChanged bool) {
-	var answer rune
-	var err error
-	var lenSearch = len(r.search)
-	var lenReplace = len(r.replace)
-	var offset = lenReplace - lenSearch
+	written = make([][]string, len(lines))
 
-	confirmedQuit := rune(*confirmAnswer) == ConfirmQuit
-	confirmedAll := rune(*confirmAnswer) == ConfirmAll
+	for i, line := range lines {
+		written[i] = []string{line.text}
+	}
 
-	replacedLine = line
+	for start, end := 0, 0; start < len(matches); start = end {
+		index := matches[start].line
 
-	for i := range len(matches) {
-		thisMatch := matches[i]
+		for end < len(matches) && matches[end].line == index {
+			end++
+		}
 
-		if confirmedQuit {
-			continue
+		if replaced := r.replaceAccepted(lines[index].text, matches[start:end]); replaced != lines[index].text {
+			written[index][0] = replaced
+			fileChanged = true
 		}
+	}
+
+	return written, fileChanged, nil
+}
+
+/**
+ * review asks for the matches in order, until all are answered or the rest of them is accepted or skipped. As answers
+ * only move forward, apart from undoing, the matches from the one being asked on are not answered yet
+ */
+func (r FileReplacer) review(ctx context.Context, matches []reviewedMatch, lines []fileLine, confirm ConfirmFunc, confirmAnswer *ConfirmAnswer) error {
+	// answered holds the matches answered, in the order they were, to be undone
+	var answered []int
 
-		stringRange := [2]int{0, thisMatch.IndexEnd}
-		if i > 0 {
-			// Gets the previous one
-			stringRange = [2]int{matches[i-1].IndexEnd + offset, thisMatch.IndexEnd + offset}
+	// notice tells, along with the next prompt, why the previous answer could not be followed
+	var notice string
+
+	for i := 0; i < len(matches); {
+		if err := ctx.Err(); err != nil {
+			return NewInterruptedError(err)
 		}
 
-		if confirmedAll {
-			answer = ConfirmYes
-		} else {
-			answer, err = ConfirmMatch(thisMatch, r.inputFilePath, lineNumber, stdin, stdout)
-			*confirmAnswer = ConfirmAnswer(answer)
+		switch rune(*confirmAnswer) {
+		case ConfirmQuit:
+			return nil
+		case ConfirmAll:
+			r.acceptFrom(matches, i)
+
+			return nil
 		}
 
+		if !r.budget.allowsPending(acceptedBefore(matches, i)) {
+			i++
+
+			continue
+		}
+
+		match := &matches[i]
+		prompt := r.matchPrompt(*match, i, lines)
+		prompt.Notice, notice = notice, ""
+
+		answer, err := confirm(prompt)
+
 		if err != nil {
-			fmt.Println(err)
+			return stopConfirming(err, confirmAnswer)
 		}
 
-		switch answer {
-		case ConfirmYes:
-			replacedLine = r.LineReplacer.ReplaceStringRange(replacedLine, stringRange)
-		case ConfirmNo:
-			// Nothing to do
+		switch answer.Choice {
+		case ConfirmYes, ConfirmNo:
+			match.accepted = answer.Choice == ConfirmYes
+		case ConfirmEdit:
+			match.accepted, match.Replace = true, answer.Replacement
 		case ConfirmAll:
-			replacedLine = r.LineReplacer.ReplaceStringRange(replacedLine, stringRange)
-			confirmedAll = true
+			*confirmAnswer = ConfirmAll
+
+			continue
+		case ConfirmAllInFile:
+			r.acceptFrom(matches, i)
+
+			return nil
+		case ConfirmSkipFile:
+			return nil
+		case ConfirmUndo:
+			if len(answered) == 0 {
+				notice = "Nothing to undo in this file"
+
+				continue
+			}
+
+			i, answered = answered[len(answered)-1], answered[:len(answered)-1]
+			matches[i].accepted, matches[i].Replace = false, r.expandReplacement(lines[matches[i].line].text, matches[i].submatches)
+
+			continue
+		case ConfirmJump:
+			if next, err := nextMatchOnLine(matches, lines, i, answer.Pattern); err != nil {
+				notice = err.Error()
+			} else {
+				i = next
+			}
+
+			continue
 		default:
-			confirmedQuit = true
+			*confirmAnswer = ConfirmQuit
+
+			return nil
+		}
+
+		answered = append(answered, i)
+		i++
+	}
+
+	return nil
+}
+
+// acceptFrom accepts the matches from `from` on, as far as the limits allow
+func (r FileReplacer) acceptFrom(matches []reviewedMatch, from int) {
+	line, file := acceptedBefore(matches, from)
+
+	for i := from; i < len(matches); i++ {
+		if i > from && matches[i].line != matches[i-1].line {
+			line = 0
+		}
+
+		if r.budget.allowsPending(line, file) {
+			matches[i].accepted = true
+			line++
+			file++
+		}
+	}
+}
+
+// acceptedBefore counts the matches accepted before `index`, in its line and in the whole file
+func acceptedBefore(matches []reviewedMatch, index int) (line, file int) {
+	for _, match := range matches[:index] {
+		if !match.accepted {
+			continue
+		}
+
+		file++
+
+		if index < len(matches) && match.line == matches[index].line {
+			line++
+		}
+	}
+
+	return line, file
+}
+
+func (r FileReplacer) matchPrompt(match reviewedMatch, index int, lines []fileLine) Prompt {
+	var before, after []string
+
+	for _, line := range lines[max(0, match.line-r.config.Context):match.line] {
+		before = append(before, line.text)
+	}
+
+	for _, line := range lines[match.line+1 : min(len(lines), match.line+1+r.config.Context)] {
+		after = append(after, line.text)
+	}
+
+	return Prompt{
+		File:          r.inputFilePath,
+		LineNumber:    match.line + 1,
+		Index:         index + 1,
+		Match:         &match.MatchString,
+		ContextBefore: before,
+		ContextAfter:  after,
+	}
+}
+
+// nextMatchOnLine finds the first match after `from` on a line matching `pattern`, failing when there is none
+func nextMatchOnLine(matches []reviewedMatch, lines []fileLine, from int, pattern string) (int, error) {
+	re, err := regexp.Compile(pattern)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for i := from + 1; i < len(matches); i++ {
+		if re.MatchString(lines[matches[i].line].text) {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("No match after this one on a line matching %q", pattern)
+}
+
+/**
+ * replaceAccepted replaces the accepted matches of a single line, as far as the limits allow. Matches are replaced
+ * at their indexes in the original line, with their expanded replacement, or the edited one if edited
+ */
+func (r FileReplacer) replaceAccepted(line string, matches []reviewedMatch) string {
+	var segments []replacedSegment
+
+	r.budget.newLine()
+
+	for _, match := range matches {
+		if !match.accepted || !r.budget.take() {
+			continue
 		}
+
+		segments = append(segments, replacedSegment{start: match.IndexStart, end: match.IndexEnd, text: match.Replace})
+	}
+
+	return joinSegments(line, segments)
+}
+
+func (r FileReplacer) confirmOperations(line string, lineNumber int, confirm ConfirmFunc, confirmAnswer *ConfirmAnswer) (replacedLines []string, lineChanged bool, err error) {
+	answer := rune(*confirmAnswer)
+
+	if answer == ConfirmQuit || !r.searchRegexp.MatchString(line) || !r.budget.allows() {
+		return []string{line}, false, nil
 	}
 
-	lineChanged = replacedLine != line
+	if answer != ConfirmAll {
+		given, err := confirm(Prompt{File: r.inputFilePath, LineNumber: lineNumber, Operations: &r.config.Operations, Line: line})
+
+		if err != nil {
+			return nil, false, stopConfirming(err, confirmAnswer)
+		}
+
+		answer = given.Choice
+		*confirmAnswer = ConfirmAnswer(answer)
+	}
+
+	if answer != ConfirmYes && answer != ConfirmAll {
+		return []string{line}, false, nil
+	}
+
+	replacedLines, lineChanged = r.replaceLine(line, lineNumber)
+
+	return replacedLines, lineChanged, nil
+}
+
+/**
+ * stopConfirming quits asking in this file and the next ones once a prompt fails, returning the error the file is
+ * left untouched with. Input ending, as on Ctrl-D, interrupts as Ctrl-C does
+ */
+func stopConfirming(err error, confirmAnswer *ConfirmAnswer) error {
+	*confirmAnswer = ConfirmQuit
+
+	if errors.Is(err, io.EOF) {
+		return NewInterruptedError(err)
+	}
 
-	return replacedLine, lineChanged
+	return err
 }
@@ -2,37 +2,38 @@ package fds
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
-	"os"
+	"log"
+	"slices"
+	"strings"
 	"testing"
 )
 
 func TestReplaceInFile_ConfirmAll(t *testing.T) {
-	tempDir := t.TempDir()
-
-	inputFile := createFiles(tempDir, "this is some text\nthis is some other text\n", t)
+	fileSystem := newTestFileSystem(map[string]string{"input": "this is some text\nthis is some other text\n"}, t)
 
 	var stdin io.Reader
 	var stdout bytes.Buffer
 
-	defer inputFile.Close()
-
 	config := NewConfig()
-	config.Flags = map[string]bool{"insensitive": false, "confirm": true, "literal": false}
+	config.FileSystem = fileSystem
+	config.Confirm = true
 
 	search := "text"
 	replace := "replacement"
 
-	fileReplacer := NewFileReplacer(inputFile.Name(), search, replace, config)
+	fileReplacer := NewFileReplacer("input", search, replace, config)
 
 	confirm := ConfirmAnswer('a')
-	outputFile, err := fileReplacer.Replace(stdin, &stdout, &confirm)
+	outputFile, err := fileReplacer.Replace(context.Background(), stdin, &stdout, &confirm)
 
 	if err != nil {
 		t.Fatalf("Failed to replace content on file: %q", err)
 	}
 
-	result, err := os.ReadFile(outputFile.Name())
+	result, err := fileSystem.ReadFile(outputFile.Name())
 
 	if err != nil {
 		t.Fatalf("Failed to read output file after find/replace: %s", err)
@@ -47,28 +48,25 @@ func TestReplaceInFile_ConfirmAll(t *testing.T) {
 
 func TestReplaceInFile_ConfirmNo(t *testing.T) {
 	var result []byte
-	tempDir := t.TempDir()
+	fileSystem := newTestFileSystem(map[string]string{"input": "this is some text\nthis is some other text\n"}, t)
 
-	inputFile := createFiles(tempDir, "this is some text\nthis is some other text\n", t)
-
-	var stdin = bytes.NewBuffer([]byte{'n'})
+	var stdin = bytes.NewBuffer([]byte{'n', 'n'})
 	var stdout bytes.Buffer
 
-	defer inputFile.Close()
-
 	config := NewConfig()
-	config.Flags = map[string]bool{"insensitive": false, "confirm": true, "literal": false}
+	config.FileSystem = fileSystem
+	config.Confirm = true
 
 	search := "text"
 	replace := "replacement"
 
-	fileReplacer := NewFileReplacer(inputFile.Name(), search, replace, config)
+	fileReplacer := NewFileReplacer("input", search, replace, config)
 
 	confirm := ConfirmAnswer('n')
-	outputFile, err := fileReplacer.Replace(stdin, &stdout, &confirm)
+	outputFile, err := fileReplacer.Replace(context.Background(), stdin, &stdout, &confirm)
 
 	if outputFile != nil {
-		result, err = os.ReadFile(outputFile.Name())
+		result, err = fileSystem.ReadFile(outputFile.Name())
 	}
 
 	if err != nil {
@@ -82,32 +80,29 @@ func TestReplaceInFile_ConfirmNo(t *testing.T) {
 
 func TestReplaceInFile_ConfirmQuit(t *testing.T) {
 	var result []byte
-	tempDir := t.TempDir()
-
-	inputFile := createFiles(tempDir, "this is some text\nthis is some other text\n", t)
+	fileSystem := newTestFileSystem(map[string]string{"input": "this is some text\nthis is some other text\n"}, t)
 
 	var stdin = bytes.NewBuffer([]byte{'q'})
 	var stdout bytes.Buffer
 
-	defer inputFile.Close()
-
 	config := NewConfig()
-	config.Flags = map[string]bool{"insensitive": false, "confirm": true, "literal": false}
+	config.FileSystem = fileSystem
+	config.Confirm = true
 
 	search := "text"
 	replace := "replacement"
 
-	fileReplacer := NewFileReplacer(inputFile.Name(), search, replace, config)
+	fileReplacer := NewFileReplacer("input", search, replace, config)
 
 	confirm := ConfirmAnswer('q')
-	outputFile, err := fileReplacer.Replace(stdin, &stdout, &confirm)
+	outputFile, err := fileReplacer.Replace(context.Background(), stdin, &stdout, &confirm)
 
 	if err != nil {
 		t.Fatalf("Failed to replace content on file: %q", err)
 	}
 
 	if outputFile != nil {
-		result, err = os.ReadFile(outputFile.Name())
+		result, err = fileSystem.ReadFile(outputFile.Name())
 	}
 
 	if err != nil {
@@ -118,3 +113,328 @@ func TestReplaceInFile_ConfirmQuit(t *testing.T) {
 		t.Errorf(`ReplaceInFile(%s, %s) should have returned nil as output file. File with content returned %s`, search, replace, result)
 	}
 }
+
+func TestReplaceInFile_ConfirmWithLimitPerFile(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "this is some text\nthis is some other text\n"}, t)
+
+	var stdin = bytes.NewBuffer([]byte{'y', 'y'})
+	var stdout bytes.Buffer
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Confirm = true
+	config.Limits = Limits{PerFile: 1}
+
+	fileReplacer := NewFileReplacer("input", "text", "replacement", config)
+
+	confirm := ConfirmAnswer('n')
+	outputFile, err := fileReplacer.Replace(context.Background(), stdin, &stdout, &confirm)
+
+	if err != nil {
+		t.Fatalf("Failed to replace content on file: %q", err)
+	}
+
+	result, _ := fileSystem.ReadFile(outputFile.Name())
+	wantText := "this is some replacement\nthis is some other text\n"
+
+	if string(result) != wantText {
+		t.Errorf(`ReplaceInFile() = %q, want %q`, result, wantText)
+	}
+}
+
+func TestReplaceInFile_ConfirmLineOperation(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "this is some text\nthis is some other text\n"}, t)
+
+	var stdin = bytes.NewBuffer([]byte{'y'})
+	var stdout bytes.Buffer
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Confirm = true
+	config.Operations = LineOperations{InsertAfter: "inserted"}
+	config.Selection.Lines.Set("1")
+
+	fileReplacer := NewFileReplacer("input", "text", "", config)
+
+	confirm := ConfirmAnswer('n')
+	outputFile, err := fileReplacer.Replace(context.Background(), stdin, &stdout, &confirm)
+
+	if err != nil {
+		t.Fatalf("Failed to replace content on file: %q", err)
+	}
+
+	result, _ := fileSystem.ReadFile(outputFile.Name())
+	wantText := "this is some text\ninserted\nthis is some other text\n"
+
+	if string(result) != wantText {
+		t.Errorf(`ReplaceInFile() = %q, want %q`, result, wantText)
+	}
+
+	if !bytes.Contains(stdout.Bytes(), []byte("+ inserted")) {
+		t.Errorf("ConfirmLineOperation() did not show the inserted line. Output: %q", stdout.String())
+	}
+}
+
+func TestReplaceInFile_ConfirmAnswers(t *testing.T) {
+	input := "foo 1\nbar foo 2\nfoo 3\nfoo 4 foo 5\n"
+
+	tests := []struct {
+		name    string
+		search  string
+		replace string
+		limits  Limits
+		answers []Answer
+		want    string
+		global  ConfirmAnswer
+	}{
+		{
+			name:    "Yes and no",
+			answers: []Answer{{Choice: ConfirmYes}, {Choice: ConfirmNo}, {Choice: ConfirmYes}, {Choice: ConfirmNo}, {Choice: ConfirmNo}},
+			want:    "baz 1\nbar foo 2\nbaz 3\nfoo 4 foo 5\n",
+			global:  ConfirmNo,
+		},
+		{
+			name:    "All in file",
+			answers: []Answer{{Choice: ConfirmNo}, {Choice: ConfirmAllInFile}},
+			want:    "foo 1\nbar baz 2\nbaz 3\nbaz 4 baz 5\n",
+			global:  ConfirmNo,
+		},
+		{
+			name:    "All in file within the limit per line",
+			limits:  Limits{PerLine: 1},
+			answers: []Answer{{Choice: ConfirmNo}, {Choice: ConfirmAllInFile}},
+			want:    "foo 1\nbar baz 2\nbaz 3\nbaz 4 foo 5\n",
+			global:  ConfirmNo,
+		},
+		{
+			name:    "All in all files",
+			answers: []Answer{{Choice: ConfirmNo}, {Choice: ConfirmAll}},
+			want:    "foo 1\nbar baz 2\nbaz 3\nbaz 4 baz 5\n",
+			global:  ConfirmAll,
+		},
+		{
+			name:    "Skip the rest of the file",
+			answers: []Answer{{Choice: ConfirmYes}, {Choice: ConfirmSkipFile}},
+			want:    "baz 1\nbar foo 2\nfoo 3\nfoo 4 foo 5\n",
+			global:  ConfirmNo,
+		},
+		{
+			name:    "Quit",
+			answers: []Answer{{Choice: ConfirmYes}, {Choice: ConfirmQuit}},
+			want:    "baz 1\nbar foo 2\nfoo 3\nfoo 4 foo 5\n",
+			global:  ConfirmQuit,
+		},
+		{
+			name:    "Undo",
+			answers: []Answer{{Choice: ConfirmYes}, {Choice: ConfirmUndo}, {Choice: ConfirmNo}, {Choice: ConfirmYes}, {Choice: ConfirmUndo}, {Choice: ConfirmUndo}, {Choice: ConfirmNo}, {Choice: ConfirmYes}, {Choice: ConfirmSkipFile}},
+			want:    "foo 1\nbar baz 2\nfoo 3\nfoo 4 foo 5\n",
+			global:  ConfirmNo,
+		},
+		{
+			name:    "Undo with nothing to undo",
+			answers: []Answer{{Choice: ConfirmUndo}, {Choice: ConfirmYes}, {Choice: ConfirmSkipFile}},
+			want:    "baz 1\nbar foo 2\nfoo 3\nfoo 4 foo 5\n",
+			global:  ConfirmNo,
+		},
+		{
+			name:    "Edit",
+			answers: []Answer{{Choice: ConfirmEdit, Replacement: "$1 edited"}, {Choice: ConfirmSkipFile}},
+			want:    "$1 edited 1\nbar foo 2\nfoo 3\nfoo 4 foo 5\n",
+			global:  ConfirmNo,
+		},
+		{
+			name:    "Jump",
+			answers: []Answer{{Choice: ConfirmJump, Pattern: "4"}, {Choice: ConfirmYes}, {Choice: ConfirmYes}},
+			want:    "foo 1\nbar foo 2\nfoo 3\nbaz 4 baz 5\n",
+			global:  ConfirmNo,
+		},
+		{
+			name:    "Jump without a match",
+			answers: []Answer{{Choice: ConfirmJump, Pattern: "6"}, {Choice: ConfirmJump, Pattern: "("}, {Choice: ConfirmYes}, {Choice: ConfirmSkipFile}},
+			want:    "baz 1\nbar foo 2\nfoo 3\nfoo 4 foo 5\n",
+			global:  ConfirmNo,
+		},
+		{
+			name:    "Groups expanded at the indexes of each match",
+			search:  `foo (\d)`,
+			replace: "${1}00",
+			answers: []Answer{{Choice: ConfirmNo}, {Choice: ConfirmYes}, {Choice: ConfirmNo}, {Choice: ConfirmYes}, {Choice: ConfirmYes}},
+			want:    "foo 1\nbar 200\nfoo 3\n400 500\n",
+			global:  ConfirmNo,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fileSystem := newTestFileSystem(map[string]string{"input": input}, t)
+
+			config := NewConfig()
+			config.FileSystem = fileSystem
+			config.Confirm = true
+			config.Limits = tc.limits
+
+			search, replace := tc.search, tc.replace
+
+			if search == "" {
+				search, replace = "foo", "baz"
+			}
+
+			var asked int
+
+			fileReplacer := NewFileReplacer("input", search, replace, config).WithConfirm(func(prompt Prompt) (Answer, error) {
+				if asked == len(tc.answers) {
+					t.Fatalf("Replace() asked %d times, want %d", asked+1, len(tc.answers))
+				}
+
+				asked++
+
+				return tc.answers[asked-1], nil
+			})
+
+			confirmAnswer := ConfirmAnswer(ConfirmNo)
+			outputFile, err := fileReplacer.Replace(context.Background(), nil, io.Discard, &confirmAnswer)
+
+			if err != nil {
+				t.Fatalf("Failed to replace content on file: %q", err)
+			}
+
+			if asked != len(tc.answers) {
+				t.Errorf("Replace() asked %d times, want %d", asked, len(tc.answers))
+			}
+
+			result, _ := fileSystem.ReadFile(outputFile.Name())
+
+			if string(result) != tc.want {
+				t.Errorf("ReplaceInFile() = %q, want %q", result, tc.want)
+			}
+
+			if confirmAnswer != tc.global {
+				t.Errorf("ReplaceInFile() left answer %q for the next files, want %q", confirmAnswer, tc.global)
+			}
+		})
+	}
+}
+
+func TestReplaceInFile_ConfirmContext(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "one\ntwo\nthree foo\nfour\nfive\nsix\n"}, t)
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Confirm = true
+	config.Context = 2
+
+	var prompts []Prompt
+
+	fileReplacer := NewFileReplacer("input", "foo", "bar", config).WithConfirm(func(prompt Prompt) (Answer, error) {
+		prompts = append(prompts, prompt)
+
+		return Answer{Choice: ConfirmNo}, nil
+	})
+
+	confirmAnswer := ConfirmAnswer(ConfirmNo)
+
+	if _, err := fileReplacer.Replace(context.Background(), nil, io.Discard, &confirmAnswer); err != nil {
+		t.Fatalf("Failed to replace content on file: %q", err)
+	}
+
+	if len(prompts) != 1 {
+		t.Fatalf("Replace() asked %d times, want once", len(prompts))
+	}
+
+	if before, after := prompts[0].ContextBefore, prompts[0].ContextAfter; !slices.Equal(before, []string{"one", "two"}) || !slices.Equal(after, []string{"four", "five"}) {
+		t.Errorf("Replace() asked with context %q and %q, want two lines before and after", before, after)
+	}
+}
+
+func TestReplaceInFile_ConfirmExpandedReplacement(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "user 1\nUser 2\n"}, t)
+
+	var logged bytes.Buffer
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Confirm = true
+	config.Verbose = true
+	config.PreserveCase = true
+	config.Logger = log.New(&logged, "", 0)
+
+	var prompted []string
+	answers := []Answer{{Choice: ConfirmYes}, {Choice: ConfirmUndo}, {Choice: ConfirmEdit, Replacement: "edited"}, {Choice: ConfirmUndo}, {Choice: ConfirmYes}, {Choice: ConfirmYes}}
+
+	fileReplacer := NewFileReplacer("input", `(user) (\d)`, "${1}_id$2", config).WithConfirm(func(prompt Prompt) (Answer, error) {
+		prompted = append(prompted, prompt.Match.Replace)
+
+		return answers[len(prompted)-1], nil
+	})
+
+	confirmAnswer := ConfirmAnswer(ConfirmNo)
+	outputFile, err := fileReplacer.Replace(context.Background(), nil, io.Discard, &confirmAnswer)
+
+	if err != nil {
+		t.Fatalf("Failed to replace content on file: %q", err)
+	}
+
+	if want := []string{"user_id1", "User_id2", "user_id1", "User_id2", "user_id1", "User_id2"}; !slices.Equal(prompted, want) {
+		t.Errorf("Replace() asked with replacements %q, want %q", prompted, want)
+	}
+
+	if result, _ := fileSystem.ReadFile(outputFile.Name()); string(result) != "user_id1\nUser_id2\n" {
+		t.Errorf("ReplaceInFile() = %q, want the expanded replacements", result)
+	}
+
+	if want := `Found "User 2" on line 2 of file input, to be replaced with "User_id2"`; !strings.Contains(logged.String(), want) {
+		t.Errorf("Replace() logged %q, want it to contain %q", logged.String(), want)
+	}
+}
+
+func TestReplaceInFile_ConfirmInterrupted(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations LineOperations
+		err        error
+	}{
+		{name: "Match interrupted", err: NewInterruptedError(nil)},
+		{name: "Match input ended", err: io.EOF},
+		{name: "Line operation interrupted", operations: LineOperations{Delete: true}, err: NewInterruptedError(nil)},
+		{name: "Line operation input ended", operations: LineOperations{Delete: true}, err: io.EOF},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fileSystem := newTestFileSystem(map[string]string{"input": "foo 1\nfoo 2\nfoo 3\n"}, t)
+
+			config := NewConfig()
+			config.FileSystem = fileSystem
+			config.Confirm = true
+			config.Operations = tc.operations
+
+			var asked int
+
+			// the first prompt is accepted, the second one fails
+			fileReplacer := NewFileReplacer("input", "foo", "bar", config).WithConfirm(func(prompt Prompt) (Answer, error) {
+				asked++
+
+				if asked == 2 {
+					return Answer{}, tc.err
+				}
+
+				return Answer{Choice: ConfirmYes}, nil
+			})
+
+			confirmAnswer := ConfirmAnswer(ConfirmNo)
+			outputFile, err := fileReplacer.Replace(context.Background(), nil, io.Discard, &confirmAnswer)
+
+			if !errors.Is(err, ErrInterrupted) || !errors.Is(err, tc.err) {
+				t.Errorf("Replace() returned error %v, want it interrupted by %v", err, tc.err)
+			}
+
+			if outputFile != nil || asked != 2 {
+				t.Errorf("Replace() asked %d times and returned %v, want no file after the second prompt", asked, outputFile)
+			}
+
+			if confirmAnswer != ConfirmQuit {
+				t.Errorf("Replace() left answer %q for the next files, want %q", confirmAnswer, ConfirmQuit)
+			}
+		})
+	}
+}
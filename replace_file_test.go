@@ -2,37 +2,36 @@ package fds
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path"
+	"strings"
 	"testing"
 )
 
 func TestFileReplacer_ReplaceInFile_SingleLine(t *testing.T) {
-	tempDir := t.TempDir()
-
-	inputFile := createFiles(tempDir, "this is some text", t)
+	fileSystem := newTestFileSystem(map[string]string{"input": "this is some text"}, t)
 	var stdin io.Reader
 	var stdout bytes.Buffer
 
-	defer inputFile.Close()
-
 	config := NewConfig()
-	config.Flags = map[string]bool{"insensitive": false, "confirm": false, "literal": false}
+	config.FileSystem = fileSystem
 	search := "text"
 	replace := "replacement"
 
-	fileReplacer := NewFileReplacer(inputFile.Name(), search, replace, config)
+	fileReplacer := NewFileReplacer("input", search, replace, config)
 
-	outputFile, err := fileReplacer.Replace(stdin, &stdout, nil)
-	defer os.Remove(outputFile.Name())
+	outputFile, err := fileReplacer.Replace(context.Background(), stdin, &stdout, nil)
 
 	if err != nil {
 		t.Fatalf("Failed to replace content on file: %q", err)
 	}
 
-	result, err := os.ReadFile(outputFile.Name())
+	result, err := fileSystem.ReadFile(outputFile.Name())
 	fmt.Println(result)
 
 	if err != nil {
@@ -47,31 +46,26 @@ func TestFileReplacer_ReplaceInFile_SingleLine(t *testing.T) {
 }
 
 func TestReplaceInFile_Multiline(t *testing.T) {
-	tempDir := t.TempDir()
-
-	inputFile := createFiles(tempDir, "this is some text\nthis is some other text\n", t)
+	fileSystem := newTestFileSystem(map[string]string{"input": "this is some text\nthis is some other text\n"}, t)
 
 	var stdin io.Reader
 	var stdout bytes.Buffer
 
-	defer inputFile.Close()
-
 	config := NewConfig()
-	config.Flags = map[string]bool{"insensitive": false, "confirm": false, "literal": false}
+	config.FileSystem = fileSystem
 
 	search := "text"
 	replace := "replacement"
 
-	fileReplacer := NewFileReplacer(inputFile.Name(), search, replace, config)
+	fileReplacer := NewFileReplacer("input", search, replace, config)
 
-	outputFile, err := fileReplacer.Replace(stdin, &stdout, nil)
-	defer os.Remove(outputFile.Name())
+	outputFile, err := fileReplacer.Replace(context.Background(), stdin, &stdout, nil)
 
 	if err != nil {
 		t.Fatalf("Failed to replace content on file: %q", err)
 	}
 
-	result, err := os.ReadFile(outputFile.Name())
+	result, err := fileSystem.ReadFile(outputFile.Name())
 
 	if err != nil {
 		t.Fatalf("Failed to read output file after find/replace: %s", err)
@@ -84,28 +78,201 @@ func TestReplaceInFile_Multiline(t *testing.T) {
 	}
 }
 
-func TestReplaceInFile_NotFound(t *testing.T) {
-	var result []byte
-	tempDir := t.TempDir()
+func TestReplaceInFile_Selection(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "text\nBEGIN\ntext\nimport text\nEND\ntext\n"}, t)
+
+	var stdin io.Reader
+	var stdout bytes.Buffer
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Selection.After.Set("BEGIN")
+	config.Selection.Before.Set("END")
+	config.Selection.Lines.Set("4:")
+
+	fileReplacer := NewFileReplacer("input", "text", "replacement", config)
+
+	outputFile, err := fileReplacer.Replace(context.Background(), stdin, &stdout, nil)
+
+	if err != nil {
+		t.Fatalf("Failed to replace content on file: %q", err)
+	}
 
-	inputFile := createFiles(tempDir, "this is some text\nthis is some other text\n", t)
+	result, _ := fileSystem.ReadFile(outputFile.Name())
+	want := "text\nBEGIN\ntext\nimport replacement\nEND\ntext\n"
+
+	if string(result) != want {
+		t.Errorf(`ReplaceInFile() = %q, want %q`, result, want)
+	}
+}
+
+func TestReplaceInFile_LineOperations(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "keep\nTODO remove\nkeep\nTODO remove"}, t)
 
 	var stdin io.Reader
 	var stdout bytes.Buffer
 
-	defer inputFile.Close()
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Operations = LineOperations{Delete: true}
+
+	fileReplacer := NewFileReplacer("input", "TODO", "", config)
+
+	outputFile, err := fileReplacer.Replace(context.Background(), stdin, &stdout, nil)
+
+	if err != nil {
+		t.Fatalf("Failed to replace content on file: %q", err)
+	}
+
+	result, _ := fileSystem.ReadFile(outputFile.Name())
+	want := "keep\nkeep\n"
+
+	if string(result) != want {
+		t.Errorf(`ReplaceInFile() = %q, want %q`, result, want)
+	}
+}
+
+func TestReplaceInFile_LineEndings(t *testing.T) {
+	var tests = []struct {
+		name       string
+		input      string
+		lineEnding LineEnding
+		want       string
+	}{
+		{
+			name:  "pattern anchored to the end of CRLF lines",
+			input: "\xEF\xBB\xBFsome text\r\nsome other text\r\n",
+			want:  "\xEF\xBB\xBFsome replacement\r\nsome other replacement\r\n",
+		},
+		{
+			name:  "pattern anchored to the end of CR lines",
+			input: "some text\rsome other text",
+			want:  "some replacement\rsome other replacement",
+		},
+		{
+			name:       "normalise to LF",
+			input:      "some text\r\nsome other text\r\n",
+			lineEnding: LF,
+			want:       "some replacement\nsome other replacement\n",
+		},
+		{
+			name:       "normalise to CRLF even without matches",
+			input:      "no match\nsome other line\n",
+			lineEnding: CRLF,
+			want:       "no match\r\nsome other line\r\n",
+		},
+		{
+			name:       "normalise to CR",
+			input:      "some text\r\nsome other text\n",
+			lineEnding: CR,
+			want:       "some replacement\rsome other replacement\r",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fileSystem := newTestFileSystem(map[string]string{"input": tc.input}, t)
+
+			config := NewConfig()
+			config.FileSystem = fileSystem
+			config.LineEnding = tc.lineEnding
+
+			fileReplacer := NewFileReplacer("input", "text$", "replacement", config)
+
+			outputFile, err := fileReplacer.Replace(context.Background(), nil, io.Discard, nil)
+
+			if err != nil {
+				t.Fatalf("Failed to replace content on file: %q", err)
+			}
+
+			result, _ := fileSystem.ReadFile(outputFile.Name())
+
+			if string(result) != tc.want {
+				t.Errorf(`ReplaceInFile() = %q, want %q`, result, tc.want)
+			}
+		})
+	}
+}
+
+func TestReplaceInFile_UTF16(t *testing.T) {
+	input := []byte{0xFF, 0xFE, 'f', 0, 'o', 0, 'o', 0, '\r', 0, '\n', 0}
+	want := []byte{0xFF, 0xFE, 'b', 0, 0xE3, 0, 'r', 0, '\r', 0, '\n', 0}
+
+	fileSystem := newTestFileSystem(map[string]string{"input": string(input)}, t)
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+
+	fileReplacer := NewFileReplacer("input", "^foo$", "bãr", config)
+
+	outputFile, err := fileReplacer.Replace(context.Background(), nil, io.Discard, nil)
+
+	if err != nil {
+		t.Fatalf("Failed to replace content on file: %q", err)
+	}
+
+	result, _ := fileSystem.ReadFile(outputFile.Name())
+
+	if !bytes.Equal(result, want) {
+		t.Errorf(`ReplaceInFile() = %v, want %v`, result, want)
+	}
+}
+
+func TestReplaceInFile_VerboseExpandedReplacement(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "user_id, group_id\nowner_id\n"}, t)
+
+	var logged bytes.Buffer
 
 	config := NewConfig()
-	config.Flags = map[string]bool{"insensitive": false, "confirm": false, "literal": false}
+	config.FileSystem = fileSystem
+	config.Verbose = true
+	config.Limits = Limits{PerLine: 1}
+	config.Logger = log.New(&logged, "", 0)
+
+	replacer := NewFileReplacer("input", `(\w+)_id`, "${1}_key", config)
+
+	if replaced, err := ReplaceInFile(context.Background(), replacer, nil, io.Discard, nil); err != nil || !replaced {
+		t.Fatalf("ReplaceInFile() = %t, %v, want the file replaced in", replaced, err)
+	}
+
+	for _, want := range []string{
+		`Replacing (\w+)_id in file input, replacements being expanded from "${1}_key"`,
+		`Replacing "user_id" on line 1 of file input with "user_key"`,
+		`Replacing "owner_id" on line 2 of file input with "owner_key"`,
+	} {
+		if !strings.Contains(logged.String(), want) {
+			t.Errorf("ReplaceInFile() logged %q, want it to contain %q", logged.String(), want)
+		}
+	}
+
+	// matches left out by the limits are not logged as replaced
+	if strings.Contains(logged.String(), "group_id") {
+		t.Errorf("ReplaceInFile() logged %q, want group_id left out", logged.String())
+	}
+
+	if result, _ := fileSystem.ReadFile("input"); string(result) != "user_key, group_id\nowner_key\n" {
+		t.Errorf("ReplaceInFile() = %q, want the expanded replacements", result)
+	}
+}
+
+func TestReplaceInFile_NotFound(t *testing.T) {
+	var result []byte
+	fileSystem := newTestFileSystem(map[string]string{"input": "this is some text\nthis is some other text\n"}, t)
+
+	var stdin io.Reader
+	var stdout bytes.Buffer
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
 
 	search := "foo"
 	replace := "replacement"
 
-	fileReplacer := NewFileReplacer(inputFile.Name(), search, replace, config)
+	fileReplacer := NewFileReplacer("input", search, replace, config)
 
-	outputFile, err := fileReplacer.Replace(stdin, &stdout, nil)
+	outputFile, err := fileReplacer.Replace(context.Background(), stdin, &stdout, nil)
 	if outputFile != nil {
-		result, err = os.ReadFile(outputFile.Name())
+		result, err = fileSystem.ReadFile(outputFile.Name())
 	}
 
 	if err != nil {
@@ -118,15 +285,72 @@ func TestReplaceInFile_NotFound(t *testing.T) {
 }
 
 func TestOpenInputFile(t *testing.T) {
+	// symlinks are resolved by the OS file system, MemoryFileSystem having none
 	tempDir := t.TempDir()
 
 	os.Create(path.Join(tempDir, "file"))
 	os.Symlink(path.Join(tempDir, "file"), path.Join(tempDir, "symlink"))
 
-	resolvedFile, _ := openInputFile(path.Join(tempDir, "symlink"))
+	resolvedFile, _ := openInputFile(OSFileSystem{}, path.Join(tempDir, "symlink"))
 	stat, _ := resolvedFile.Stat()
 
 	if stat.Mode() == os.ModeSymlink {
 		t.Errorf("OpenInputFile() resolved a symlink instead of file")
 	}
 }
+
+func TestReplaceInFile_Interrupted(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"input": "this is some text\nthis is some other text\n"}, t)
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fileReplacer := NewFileReplacer("input", "text", "replacement", config)
+
+	outputFile, err := fileReplacer.Replace(ctx, nil, io.Discard, nil)
+
+	if outputFile != nil || !errors.Is(err, ErrInterrupted) {
+		t.Errorf("Replace() = %v, %v, want no output file and an interrupted error", outputFile, err)
+	}
+
+	if names := fileSystem.Names(); len(names) != 1 {
+		t.Errorf("Replace() left files %q behind, want only the input file", names)
+	}
+}
+
+// BenchmarkFileReplacer_NoMatch compares skipping a file without matches with splitting it into lines, which
+// normalising line endings requires
+func BenchmarkFileReplacer_NoMatch(b *testing.B) {
+	content := strings.Repeat("lorem ipsum dolor sit amet, consectetur adipiscing elit\n", 20000)
+
+	benchmarks := []struct {
+		name       string
+		lineEnding LineEnding
+	}{
+		{name: "Prefiltered"},
+		{name: "Line by line", lineEnding: LF},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			fileSystem := newTestFileSystem(map[string]string{"input": content}, b)
+
+			config := NewConfig()
+			config.FileSystem = fileSystem
+			config.LineEnding = bm.lineEnding
+
+			b.SetBytes(int64(len(content)))
+
+			for b.Loop() {
+				outputFile, err := NewFileReplacer("input", `foo\d+`, "bar", config).Replace(context.Background(), nil, io.Discard, nil)
+
+				if err != nil || outputFile != nil {
+					b.Fatalf("Replace() = %v, %v, want no file and no error", outputFile, err)
+				}
+			}
+		})
+	}
+}
@@ -2,44 +2,57 @@ package fds
 
 import (
 	"regexp"
-	"slices"
+	"strings"
 )
 
-type Replacer interface {
-	HasFlag(flag string) bool
-}
-
 type LineReplacer struct {
-	flags map[string]bool
+	config Config
 
 	search       string
 	searchRegexp *regexp.Regexp
 	replace      string
+
+	budget *replaceBudget
 }
 
-func NewLineReplacer(search, replace string, flags map[string]bool) LineReplacer {
-	replacer := LineReplacer{flags: flags, replace: replace, search: search}
+func NewLineReplacer(search, replace string, config Config) LineReplacer {
+	replacer := LineReplacer{config: config, replace: replace, search: search}
 	replacer.searchRegexp = replacer.compilePattern(search)
 
 	return replacer
 }
 
+// WithLimits returns a copy of the replacer that stops replacing once any of `limits` is reached
+func (s LineReplacer) WithLimits(limits Limits) LineReplacer {
+	s.budget = newReplaceBudget(limits, nil)
+
+	return s
+}
+
 func (s LineReplacer) compilePattern(search string) *regexp.Regexp {
 	searchWithModifiers := search
 
-	if s.flags["literal"] {
+	if s.config.Literal {
 		searchWithModifiers = regexp.QuoteMeta(search)
 	}
 
-	if s.flags["insensitive"] {
+	if s.config.Insensitive {
 		searchWithModifiers = "(?i)" + search
 	}
 
+	if s.config.PreserveCase {
+		searchWithModifiers = "(?i)" + searchWithModifiers
+	}
+
 	return regexp.MustCompile(searchWithModifiers)
 }
 
 func (s LineReplacer) Replace(subject string) (result string, replaced bool) {
-	result = s.searchRegexp.ReplaceAllString(subject, s.replace)
+	if s.budget != nil {
+		s.budget.newLine()
+	}
+
+	result = s.replaceAll(subject)
 
 	if result != subject {
 		replaced = true
@@ -48,22 +61,78 @@ func (s LineReplacer) Replace(subject string) (result string, replaced bool) {
 	return
 }
 
-func (s LineReplacer) HasFlag(flag string) bool {
-	return s.flags[flag]
-}
-
 /**
  * ReplaceStringRange replaces a given string or pattern when found in a range defined in `stringRange`
- * All other matches found out of the supplied range are ignored and therefore, not replaced
+ * All other matches found out of the supplied range are ignored and therefore, not replaced. Matches are found on the
+ * whole subject, as they are when replacing all of them, so only the ones lying within the range are replaced
  */
 func (r LineReplacer) ReplaceStringRange(subject string, stringRange [2]int) string {
-	var prepend, append []byte
+	var segments []replacedSegment
 
-	subjectSubstring := []byte(subject)[stringRange[0]:stringRange[1]]
-	replaced := r.searchRegexp.ReplaceAll(subjectSubstring, []byte(r.replace))
+	// anchors and word boundaries see the text around the range
+	for _, submatch := range r.searchRegexp.FindAllStringSubmatchIndex(subject, -1) {
+		if submatch[0] >= stringRange[0] && submatch[1] <= stringRange[1] {
+			segments = append(segments, r.segment(subject, submatch))
+		}
+	}
 
-	prepend = []byte(subject)[0:stringRange[0]]
-	append = []byte(subject)[stringRange[1]:]
+	return joinSegments(subject, segments)
+}
+
+func (s LineReplacer) replaceAll(subject string) string {
+	if s.budget == nil && !s.config.PreserveCase {
+		return s.searchRegexp.ReplaceAllString(subject, s.replace)
+	}
+
+	return joinSegments(subject, s.segments(subject))
+}
+
+// lineSegments is the matches of a single line to be replaced, along with their expanded replacement
+func (s LineReplacer) lineSegments(line string) []replacedSegment {
+	if s.budget != nil {
+		s.budget.newLine()
+	}
+
+	return s.segments(line)
+}
+
+// segments is the matches of `subject` to be replaced, as far as the limits allow
+func (s LineReplacer) segments(subject string) []replacedSegment {
+	var segments []replacedSegment
+	var previousEnd int
+
+	for _, submatch := range s.searchRegexp.FindAllStringSubmatchIndex(subject, -1) {
+		if s.budget != nil {
+			// a subject may hold several lines (e.g. stdin), so the per-line count restarts on every line break
+			if strings.Contains(subject[previousEnd:submatch[0]], "\n") {
+				s.budget.newLine()
+			}
+
+			previousEnd = submatch[1]
+
+			if !s.budget.take() {
+				continue
+			}
+		}
+
+		segments = append(segments, s.segment(subject, submatch))
+	}
+
+	return segments
+}
+
+// segment is the match at `submatch` of `subject` along with its expanded replacement
+func (s LineReplacer) segment(subject string, submatch []int) replacedSegment {
+	return replacedSegment{start: submatch[0], end: submatch[1], text: s.expandReplacement(subject, submatch)}
+}
+
+// expandReplacement expands `$1`-like references of the replacement for a single match, adapting its case when requested
+func (s LineReplacer) expandReplacement(subject string, submatch []int) string {
+	expanded := string(s.searchRegexp.ExpandString(nil, s.replace, subject, submatch))
+
+	if s.config.PreserveCase {
+		expanded = PreserveCase(subject[submatch[0]:submatch[1]], expanded)
+	}
 
-	return string(slices.Concat(prepend, replaced, append))
+	return expanded
 }
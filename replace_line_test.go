@@ -34,7 +34,7 @@ func TestLineReplacer_Replace(t *testing.T) {
 		subject string
 		search  string
 		replace string
-		flags   map[string]bool
+		config  Config
 		want    *regexp.Regexp
 	}{
 		{
@@ -42,7 +42,7 @@ func TestLineReplacer_Replace(t *testing.T) {
 			search:  "text",
 			replace: "replacement",
 			subject: "this is some text, this is some other text",
-			flags:   map[string]bool{"insensitive": false, "confirm": false, "literal": true},
+			config:  Config{Literal: true},
 			want:    regexp.MustCompile("this is some replacement, this is some other replacement"),
 		},
 		{
@@ -50,7 +50,7 @@ func TestLineReplacer_Replace(t *testing.T) {
 			search:  "t.xt",
 			replace: "replacement",
 			subject: "this is some text",
-			flags:   map[string]bool{"insensitive": false, "confirm": false, "literal": false},
+			config:  Config{},
 			want:    regexp.MustCompile("this is some replacement"),
 		},
 		{
@@ -58,7 +58,7 @@ func TestLineReplacer_Replace(t *testing.T) {
 			search:  "Text",
 			replace: "replacement",
 			subject: "this is some text",
-			flags:   map[string]bool{"insensitive": true, "confirm": false, "literal": false},
+			config:  Config{Insensitive: true},
 			want:    regexp.MustCompile("this is some replacement"),
 		},
 		{
@@ -66,7 +66,7 @@ func TestLineReplacer_Replace(t *testing.T) {
 			search:  "(text)",
 			replace: "other $1",
 			subject: "this is some text",
-			flags:   map[string]bool{"insensitive": false, "confirm": false, "literal": false},
+			config:  Config{},
 			want:    regexp.MustCompile("this is some other text"),
 		},
 		{
@@ -74,22 +74,38 @@ func TestLineReplacer_Replace(t *testing.T) {
 			search:  "<fooo>",
 			replace: "replacement",
 			subject: "this is some text, this is some other text",
-			flags:   map[string]bool{"insensitive": false, "confirm": false, "literal": true},
+			config:  Config{Literal: true},
 			want:    regexp.MustCompile("this is some text, this is some other text"),
 		},
+		{
+			name:    "preserve case of each match",
+			search:  "user",
+			replace: "account",
+			subject: "user userId User USER",
+			config:  Config{PreserveCase: true},
+			want:    regexp.MustCompile("^account accountId Account ACCOUNT$"),
+		},
+		{
+			name:    "preserve case with capturing group",
+			search:  "(user)_?(id)",
+			replace: "${1}_account_$2",
+			subject: "user_id USER_ID UserId",
+			config:  Config{PreserveCase: true},
+			want:    regexp.MustCompile("^user_account_id USER_ACCOUNT_ID UserAccountId$"),
+		},
 		{
 			name:    "literal not match",
 			search:  "<fooo>",
 			replace: "replacement",
 			subject: "this is some text, this is some other text",
-			flags:   map[string]bool{"insensitive": false, "confirm": false, "literal": true},
+			config:  Config{Literal: true},
 			want:    regexp.MustCompile("this is some text, this is some other text"),
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			replacer := NewLineReplacer(tc.search, tc.replace, tc.flags)
+			replacer := NewLineReplacer(tc.search, tc.replace, tc.config)
 			result, _ := replacer.Replace(tc.subject)
 
 			if !tc.want.MatchString(result) {
@@ -106,7 +122,7 @@ func TestLineReplacer_ReplaceStringRange(t *testing.T) {
 		search      string
 		replace     string
 		stringRange [2]int
-		flags       map[string]bool
+		config      Config
 		want        string
 	}{
 		{
@@ -115,7 +131,7 @@ func TestLineReplacer_ReplaceStringRange(t *testing.T) {
 			search:      "text",
 			replace:     "replacement",
 			stringRange: [2]int{0, 17},
-			flags:       map[string]bool{"insensitive": false, "confirm": false, "literal": false},
+			config:      Config{},
 			want:        "this is some replacement, this is the rest of the text",
 		},
 		{
@@ -124,7 +140,7 @@ func TestLineReplacer_ReplaceStringRange(t *testing.T) {
 			search:      "this",
 			replace:     "that",
 			stringRange: [2]int{17, 35},
-			flags:       map[string]bool{"insensitive": false, "confirm": false, "literal": false},
+			config:      Config{},
 			want:        "this is some text, that is the rest of the text",
 		},
 		{
@@ -133,23 +149,50 @@ func TestLineReplacer_ReplaceStringRange(t *testing.T) {
 			search:      "text",
 			replace:     "replacement",
 			stringRange: [2]int{42, 47},
-			flags:       map[string]bool{"insensitive": false, "confirm": false, "literal": false},
+			config:      Config{},
 			want:        "this is some text, this is the rest of the replacement",
 		},
+		{
+			name:        "string range with preserve case",
+			subject:     "User is the user",
+			search:      "user",
+			replace:     "account",
+			stringRange: [2]int{0, 4},
+			config:      Config{PreserveCase: true},
+			want:        "Account is the user",
+		},
+		{
+			name:        "string range matched on the whole subject",
+			subject:     "abab",
+			search:      `\Bb`,
+			replace:     "X",
+			stringRange: [2]int{3, 4},
+			config:      Config{},
+			want:        "abaX",
+		},
+		{
+			name:        "string range with groups",
+			subject:     "key=value key=other",
+			search:      `(\w+)=(\w+)`,
+			replace:     "$2=$1",
+			stringRange: [2]int{10, 19},
+			config:      Config{},
+			want:        "key=value other=key",
+		},
 		{
 			name:        "no match",
 			subject:     "this is some text, this is the rest of the text",
 			search:      "banana",
 			replace:     "that",
 			stringRange: [2]int{42, 47},
-			flags:       map[string]bool{"insensitive": false, "confirm": false, "literal": false},
+			config:      Config{},
 			want:        "this is some text, this is the rest of the text",
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			replacer := NewLineReplacer(tc.search, tc.replace, tc.flags)
+			replacer := NewLineReplacer(tc.search, tc.replace, tc.config)
 			result := replacer.ReplaceStringRange(tc.subject, tc.stringRange)
 
 			if result != tc.want {
@@ -158,3 +201,42 @@ func TestLineReplacer_ReplaceStringRange(t *testing.T) {
 		})
 	}
 }
+
+func TestLineReplacer_ReplaceWithLimits(t *testing.T) {
+	var tests = []struct {
+		name    string
+		subject string
+		limits  Limits
+		want    string
+	}{
+		{
+			name:    "first occurrence per line",
+			subject: "foo foo\nfoo foo",
+			limits:  Limits{PerLine: 1},
+			want:    "bar foo\nbar foo",
+		},
+		{
+			name:    "limit per file",
+			subject: "foo foo\nfoo foo",
+			limits:  Limits{PerFile: 3},
+			want:    "bar bar\nbar foo",
+		},
+		{
+			name:    "no limits",
+			subject: "foo foo\nfoo foo",
+			limits:  Limits{},
+			want:    "bar bar\nbar bar",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			replacer := NewLineReplacer("foo", "bar", Config{}).WithLimits(tc.limits)
+			result, _ := replacer.Replace(tc.subject)
+
+			if result != tc.want {
+				t.Errorf("Replacer.Replace() = %q, want %q", result, tc.want)
+			}
+		})
+	}
+}
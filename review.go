@@ -0,0 +1,228 @@
+package fds
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+)
+
+/**
+ * Review holds the matches found in files, to be selected before being replaced all at once. The matches are found
+ * and replaced by running with Config.Confirm set, answering the prompts of the matches instead of asking them
+ */
+type Review struct {
+	Files []*ReviewFile
+
+	replacer LineReplacer
+}
+
+// ReviewFile is a file with matches, listed in the order it was found
+type ReviewFile struct {
+	Path    string
+	Matches []*ReviewMatch
+
+	// Collapsed hides the matches of the file when listed
+	Collapsed bool
+
+	// digest is the hash of the content the matches were found in, to tell whether the file was modified since
+	digest [sha256.Size]byte
+}
+
+// ReviewMatch is a match as it was prompted, along with whether it is selected to be replaced
+type ReviewMatch struct {
+	Prompt
+	Selected bool
+}
+
+type reviewKey struct {
+	path       string
+	lineNumber int
+	indexStart int
+}
+
+// NewReview finds the matches of `options` in its files, with every match selected
+func NewReview(ctx context.Context, options Options) (*Review, error) {
+	review := &Review{replacer: NewLineReplacer(options.Search, options.Replace, options.Config)}
+	files := map[string]*ReviewFile{}
+
+	// matches are asked for as they are when confirming, so that they are found the same way
+	options.Config.Confirm, options.Config.Review = true, true
+	options.Confirm = func(prompt Prompt) (Answer, error) {
+		if prompt.Match == nil {
+			return Answer{Choice: ConfirmNo}, nil
+		}
+
+		file, ok := files[prompt.File]
+
+		if !ok {
+			file = &ReviewFile{Path: prompt.File}
+			file.digest, _ = fileDigest(options.Config.fileSystem(), prompt.File)
+			files[prompt.File] = file
+			review.Files = append(review.Files, file)
+		}
+
+		file.Matches = append(file.Matches, &ReviewMatch{Prompt: prompt, Selected: true})
+
+		return Answer{Choice: ConfirmNo}, nil
+	}
+
+	_, err := Run(ctx, options)
+
+	return review, err
+}
+
+// Count tells how many matches there are, and how many of them are selected
+func (r *Review) Count() (matches, selected int) {
+	for _, file := range r.Files {
+		matches += len(file.Matches)
+		selected += file.Selected()
+	}
+
+	return matches, selected
+}
+
+/**
+ * Replaced returns the line of `match` with only `match` replaced, as far as it is shown in the match. The replacement
+ * is expanded from the groups of the match as found on the whole line, not found again in the part shown
+ */
+func (r *Review) Replaced(match *ReviewMatch) (before, after string) {
+	before = match.Match.Before + match.Match.Search + match.Match.After
+	submatch := shiftIndexes(match.Match.submatches, len(match.Match.Before)-match.Match.IndexStart)
+
+	return before, joinSegments(before, []replacedSegment{r.replacer.segment(before, submatch)})
+}
+
+/**
+ * Apply replaces the selected matches of the files with any, through Run and ReplaceInFile, as `options` would. Files
+ * modified since they were reviewed are not overwritten, each being reported with an aborted operation error
+ */
+func (r *Review) Apply(ctx context.Context, options Options) (Report, error) {
+	selected := map[reviewKey]bool{}
+	modified := map[string]bool{}
+	options.Paths = nil
+
+	// applied are the files with matches selected, whether modified or not, in the order they were found
+	var applied []string
+
+	for _, file := range r.Files {
+		for _, match := range file.Matches {
+			if match.Selected {
+				selected[reviewKey{file.Path, match.LineNumber, match.Match.IndexStart}] = true
+			}
+		}
+
+		if file.Selected() == 0 {
+			continue
+		}
+
+		applied = append(applied, file.Path)
+
+		if file.modified(options.Config.fileSystem()) {
+			modified[file.Path] = true
+		} else {
+			options.Paths = append(options.Paths, file.Path)
+		}
+	}
+
+	if len(applied) == 0 {
+		return Report{}, nil
+	}
+
+	var report Report
+	var err error
+
+	if len(options.Paths) > 0 {
+		// the matches selected are replaced by answering their prompts
+		options.Config.Confirm, options.Config.Review = true, true
+		options.Confirm = func(prompt Prompt) (Answer, error) {
+			if prompt.Match != nil && selected[reviewKey{prompt.File, prompt.LineNumber, prompt.Match.IndexStart}] {
+				return Answer{Choice: ConfirmYes}, nil
+			}
+
+			return Answer{Choice: ConfirmNo}, nil
+		}
+
+		report, err = Run(ctx, options)
+	}
+
+	if len(modified) == 0 {
+		return report, err
+	}
+
+	reports := map[string]FileReport{}
+
+	for _, file := range report.Files {
+		reports[file.Path] = file
+	}
+
+	var files []FileReport
+
+	for _, path := range applied {
+		if modified[path] {
+			files = append(files, FileReport{Path: path, Err: NewAbortedOperationError(path)})
+		} else if file, ok := reports[path]; ok {
+			files = append(files, file)
+		}
+	}
+
+	merged := newReport(files)
+
+	// errors of the run itself, rather than of its files, are returned as they are
+	if errors.Is(err, ErrInterrupted) || err != nil && len(report.Files) == 0 {
+		return merged, err
+	}
+
+	if len(applied) == 1 {
+		return merged, merged.Files[0].Err
+	}
+
+	return merged, merged.filesError(len(applied))
+}
+
+// modified tells whether the file was modified since it was reviewed. Files that cannot be read are left to Run
+func (f *ReviewFile) modified(fileSystem FileSystem) bool {
+	digest, err := fileDigest(fileSystem, f.Path)
+
+	return err == nil && digest != f.digest
+}
+
+func fileDigest(fileSystem FileSystem, path string) (digest [sha256.Size]byte, err error) {
+	file, err := fileSystem.Open(path)
+
+	if err != nil {
+		return digest, err
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+
+	if _, err = io.Copy(hash, file); err != nil {
+		return digest, err
+	}
+
+	copy(digest[:], hash.Sum(nil))
+
+	return digest, nil
+}
+
+// Selected tells how many matches of the file are selected
+func (f *ReviewFile) Selected() int {
+	var selected int
+
+	for _, match := range f.Matches {
+		if match.Selected {
+			selected++
+		}
+	}
+
+	return selected
+}
+
+// Select selects all matches of the file, or none of them
+func (f *ReviewFile) Select(selected bool) {
+	for _, match := range f.Matches {
+		match.Selected = selected
+	}
+}
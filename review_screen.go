@@ -0,0 +1,398 @@
+package fds
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	screenEnter      = "\x1b[?1049h\x1b[?25l"
+	screenLeave      = "\x1b[?25h\x1b[?1049l"
+	screenHome       = "\x1b[H"
+	screenClearLine  = "\x1b[K"
+	screenInverse    = "\x1b[7m"
+	screenRed        = "\x1b[31m"
+	screenGreen      = "\x1b[32m"
+	screenReset      = "\x1b[0m"
+	screenLineBreak  = "\r\n"
+	screenMaxPreview = 9
+
+	reviewHelp = "↑↓ move  ←→ collapse/expand  space toggle  a all  n none  enter apply  q quit"
+)
+
+// reviewRow is a line of the tree of a ReviewScreen: either a file, when match is nil, or a match of the file
+type reviewRow struct {
+	file  *ReviewFile
+	match *ReviewMatch
+}
+
+/**
+ * ReviewScreen is a full-screen terminal UI listing the files and matches of a Review in a tree, with a side-by-side
+ * preview of the line of the match before and after being replaced. Matches, or whole files, are selected with the
+ * keyboard before the selection is applied. It expects the terminal in raw mode, so that each key is read as typed
+ */
+type ReviewScreen struct {
+	review *Review
+	input  io.Reader
+	output io.Writer
+	size   func() (width, height int)
+
+	cursor     int
+	offset     int
+	confirming bool
+}
+
+func NewReviewScreen(review *Review, input io.Reader, output io.Writer, size func() (width, height int)) *ReviewScreen {
+	return &ReviewScreen{review: review, input: input, output: output, size: size}
+}
+
+/**
+ * Run shows the screen until the selection is applied, returning true, or until the review is quit, with q, Ctrl+C,
+ * the end of the input or `ctx` being done. Keys are read on a goroutine left blocked on the input when `ctx` is done
+ */
+func (s *ReviewScreen) Run(ctx context.Context) (apply bool, err error) {
+	ctx, stopReading := context.WithCancel(ctx)
+	defer stopReading()
+
+	keys := make(chan string)
+	failed := make(chan error, 1)
+
+	go func() {
+		reader := bufio.NewReader(s.input)
+
+		for {
+			key, err := readKey(reader)
+
+			if err != nil {
+				failed <- err
+
+				return
+			}
+
+			select {
+			case keys <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	fmt.Fprint(s.output, screenEnter)
+	defer fmt.Fprint(s.output, screenLeave)
+
+	for {
+		s.draw()
+
+		select {
+		case <-ctx.Done():
+			return false, NewInterruptedError(ctx.Err())
+		case err := <-failed:
+			if err == io.EOF {
+				return false, nil
+			}
+
+			return false, err
+		case key := <-keys:
+			if done, apply := s.handle(key); done {
+				return apply, nil
+			}
+		}
+	}
+}
+
+// readKey reads a single key, arrows and page keys being escape sequences
+func readKey(reader *bufio.Reader) (string, error) {
+	char, _, err := reader.ReadRune()
+
+	if err != nil {
+		return "", err
+	}
+
+	if char != '\x1b' || reader.Buffered() == 0 {
+		return string(char), nil
+	}
+
+	if next, _ := reader.Peek(1); next[0] != '[' {
+		return string(char), nil
+	}
+
+	reader.ReadByte()
+
+	var sequence []byte
+
+	for {
+		b, err := reader.ReadByte()
+
+		if err != nil {
+			return "", err
+		}
+
+		sequence = append(sequence, b)
+
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+
+	switch string(sequence) {
+	case "A":
+		return "up", nil
+	case "B":
+		return "down", nil
+	case "C":
+		return "right", nil
+	case "D":
+		return "left", nil
+	case "H", "1~":
+		return "home", nil
+	case "F", "4~":
+		return "end", nil
+	case "5~":
+		return "pgup", nil
+	case "6~":
+		return "pgdown", nil
+	}
+
+	return "", nil
+}
+
+// handle handles a key, telling whether the review is done and, if so, whether the selection is to be applied
+func (s *ReviewScreen) handle(key string) (done, apply bool) {
+	if s.confirming {
+		s.confirming = false
+
+		return key == "y", key == "y"
+	}
+
+	rows := s.rows()
+	_, listHeight, _ := s.layout()
+
+	switch key {
+	case "q", "\x03":
+		return true, false
+	case "up", "k":
+		s.cursor--
+	case "down", "j":
+		s.cursor++
+	case "pgup":
+		s.cursor -= listHeight
+	case "pgdown":
+		s.cursor += listHeight
+	case "home", "g":
+		s.cursor = 0
+	case "end", "G":
+		s.cursor = len(rows) - 1
+	case "left", "h":
+		if len(rows) > 0 {
+			file := rows[s.cursor].file
+			file.Collapsed = true
+			s.cursor = s.fileRow(file)
+		}
+	case "right", "l":
+		if len(rows) > 0 {
+			rows[s.cursor].file.Collapsed = false
+		}
+	case " ":
+		if len(rows) > 0 {
+			row := rows[s.cursor]
+
+			if row.match != nil {
+				row.match.Selected = !row.match.Selected
+			} else {
+				row.file.Select(row.file.Selected() < len(row.file.Matches))
+			}
+		}
+	case "a", "n":
+		for _, file := range s.review.Files {
+			file.Select(key == "a")
+		}
+	case "\r", "\n":
+		s.confirming = true
+	}
+
+	s.cursor = max(0, min(s.cursor, len(s.rows())-1))
+
+	return false, false
+}
+
+func (s *ReviewScreen) rows() []reviewRow {
+	var rows []reviewRow
+
+	for _, file := range s.review.Files {
+		rows = append(rows, reviewRow{file: file})
+
+		if file.Collapsed {
+			continue
+		}
+
+		for _, match := range file.Matches {
+			rows = append(rows, reviewRow{file: file, match: match})
+		}
+	}
+
+	return rows
+}
+
+func (s *ReviewScreen) fileRow(file *ReviewFile) int {
+	for i, row := range s.rows() {
+		if row.file == file && row.match == nil {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// layout splits the height of the screen between the tree and the preview, around a header, a separator and a footer
+func (s *ReviewScreen) layout() (width, listHeight, previewHeight int) {
+	width, height := s.size()
+	available := max(0, height-3)
+	previewHeight = min(screenMaxPreview, available/2)
+
+	return width, available - previewHeight, previewHeight
+}
+
+func (s *ReviewScreen) draw() {
+	width, listHeight, previewHeight := s.layout()
+	rows := s.rows()
+	matches, selected := s.review.Count()
+
+	if s.cursor < s.offset {
+		s.offset = s.cursor
+	}
+
+	if s.cursor >= s.offset+listHeight {
+		s.offset = s.cursor - listHeight + 1
+	}
+
+	var lines []string
+
+	lines = append(lines, screenInverse+fitWidth(fmt.Sprintf("fds review: %d matches in %d files, %d selected", matches, len(s.review.Files), selected), width)+screenReset)
+
+	for i := s.offset; i < s.offset+listHeight; i++ {
+		if i >= len(rows) {
+			lines = append(lines, "")
+
+			continue
+		}
+
+		line := fitWidth(rowText(rows[i]), width)
+
+		if i == s.cursor {
+			line = screenInverse + line + screenReset
+		}
+
+		lines = append(lines, line)
+	}
+
+	column := max(0, (width-3)/2)
+	lines = append(lines, fitWidth(strings.Repeat("─", column)+"─┬─"+strings.Repeat("─", column), width))
+	lines = append(lines, s.preview(rows, column, previewHeight)...)
+
+	footer := reviewHelp
+
+	if s.confirming {
+		footer = fmt.Sprintf("Apply %d selected replacements? [y/n]", selected)
+	}
+
+	lines = append(lines, fitWidth(footer, width))
+
+	fmt.Fprint(s.output, screenHome)
+
+	for i, line := range lines {
+		if i > 0 {
+			fmt.Fprint(s.output, screenLineBreak)
+		}
+
+		fmt.Fprint(s.output, line+screenClearLine)
+	}
+}
+
+// preview shows the match under the cursor, or the first match of the file under it, before and after replacing
+func (s *ReviewScreen) preview(rows []reviewRow, column, height int) []string {
+	lines := make([]string, height)
+
+	if len(rows) == 0 {
+		return lines
+	}
+
+	row := rows[s.cursor]
+	match := row.match
+
+	if match == nil && len(row.file.Matches) > 0 {
+		match = row.file.Matches[0]
+	}
+
+	if match == nil {
+		return lines
+	}
+
+	before, after := s.review.Replaced(match)
+	firstLine := match.LineNumber - len(match.ContextBefore)
+
+	var preview []string
+
+	side := func(number int, left, right, leftColor, rightColor string) string {
+		prefix := strconv.Itoa(number) + " "
+
+		return leftColor + fitWidth(prefix+left, column) + screenReset + " │ " + rightColor + fitWidth(prefix+right, column) + screenReset
+	}
+
+	for i, line := range match.ContextBefore {
+		preview = append(preview, side(firstLine+i, line, line, "", ""))
+	}
+
+	preview = append(preview, side(match.LineNumber, before, after, screenRed, screenGreen))
+
+	for i, line := range match.ContextAfter {
+		preview = append(preview, side(match.LineNumber+1+i, line, line, "", ""))
+	}
+
+	copy(lines, preview)
+
+	return lines
+}
+
+func rowText(row reviewRow) string {
+	if row.match != nil {
+		mark := "[ ]"
+
+		if row.match.Selected {
+			mark = "[x]"
+		}
+
+		return fmt.Sprintf("    %s %d: %s", mark, row.match.LineNumber, row.match.Match.Before+row.match.Match.Search+row.match.Match.After)
+	}
+
+	mark, selected := "[~]", row.file.Selected()
+
+	switch selected {
+	case 0:
+		mark = "[ ]"
+	case len(row.file.Matches):
+		mark = "[x]"
+	}
+
+	arrow := "▾"
+
+	if row.file.Collapsed {
+		arrow = "▸"
+	}
+
+	return fmt.Sprintf("%s %s %s (%d/%d)", arrow, mark, row.file.Path, selected, len(row.file.Matches))
+}
+
+// fitWidth pads or cuts `text` to `width` characters, tabs being shown as spaces so as not to break the columns
+func fitWidth(text string, width int) string {
+	runes := []rune(strings.ReplaceAll(text, "\t", "    "))
+
+	if len(runes) > width {
+		return string(runes[:max(0, width)])
+	}
+
+	return string(runes) + strings.Repeat(" ", width-len(runes))
+}
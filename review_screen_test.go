@@ -0,0 +1,158 @@
+package fds
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func runTestReviewScreen(t *testing.T, input string) (*Review, bool, string, error) {
+	review, _, _ := newTestReview(t)
+
+	var output bytes.Buffer
+
+	size := func() (int, int) { return 60, 20 }
+	apply, err := NewReviewScreen(review, strings.NewReader(input), &output, size).Run(context.Background())
+
+	return review, apply, output.String(), err
+}
+
+func TestReviewScreen_Run(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantApply bool
+		want      []bool
+	}{
+		{name: "Applies all", input: "\ry", wantApply: true, want: []bool{true, true, true}},
+		{name: "Toggles a match", input: "j \ry", wantApply: true, want: []bool{false, true, true}},
+		{name: "Toggles a file", input: "Gk \x1b[A\x1b[B\ry", wantApply: true, want: []bool{true, true, false}},
+		{name: "Toggles a collapsed file", input: "jh \ry", wantApply: true, want: []bool{false, false, true}},
+		{name: "Selects none, then one", input: "nG \ry", wantApply: true, want: []bool{false, false, true}},
+		{name: "Declines to apply", input: "\rnq", wantApply: false, want: []bool{true, true, true}},
+		{name: "Quits", input: "j q", wantApply: false, want: []bool{false, true, true}},
+		{name: "Ctrl+C", input: "\x03", wantApply: false, want: []bool{true, true, true}},
+		{name: "End of input", input: "j", wantApply: false, want: []bool{true, true, true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			review, apply, _, err := runTestReviewScreen(t, tc.input)
+
+			if err != nil {
+				t.Fatalf("ReviewScreen.Run() returned an unexpected error '%s'", err)
+			}
+
+			if apply != tc.wantApply {
+				t.Errorf("ReviewScreen.Run() = %v, want %v", apply, tc.wantApply)
+			}
+
+			var selected []bool
+
+			for _, file := range review.Files {
+				for _, match := range file.Matches {
+					selected = append(selected, match.Selected)
+				}
+			}
+
+			if !slices.Equal(selected, tc.want) {
+				t.Errorf("ReviewScreen.Run() selected %v, want %v", selected, tc.want)
+			}
+		})
+	}
+}
+
+func TestReviewScreen_Draw(t *testing.T) {
+	_, _, output, _ := runTestReviewScreen(t, "jq")
+
+	for _, want := range []string{
+		screenEnter,
+		"fds review: 3 matches in 2 files, 3 selected",
+		"▾ [x] /src/a (2/2)",
+		"    [x] 1: foo foo",
+		"1 foo foo",
+		"1 baz foo",
+		"2 bar",
+		screenLeave,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("ReviewScreen.Run() drew %q, does not contain %q", output, want)
+		}
+	}
+}
+
+func TestReviewScreen_Cancelled(t *testing.T) {
+	review, _, _ := newTestReview(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the input never ends, as a terminal left untouched
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	_, err := NewReviewScreen(review, reader, &bytes.Buffer{}, func() (int, int) { return 60, 20 }).Run(ctx)
+
+	if !errors.Is(err, ErrInterrupted) {
+		t.Errorf("ReviewScreen.Run() returned %v, want %v", err, ErrInterrupted)
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "jk ", want: []string{"j", "k", " "}},
+		{input: "\x1b[A\x1b[B\x1b[C\x1b[D", want: []string{"up", "down", "right", "left"}},
+		{input: "\x1b[H\x1b[F\x1b[1~\x1b[4~", want: []string{"home", "end", "home", "end"}},
+		{input: "\x1b[5~\x1b[6~", want: []string{"pgup", "pgdown"}},
+		{input: "\x1b[2~x", want: []string{"", "x"}},
+		{input: "\x1b", want: []string{"\x1b"}},
+		{input: "ã\r", want: []string{"ã", "\r"}},
+	}
+
+	for _, tc := range tests {
+		reader := bufio.NewReader(strings.NewReader(tc.input))
+
+		var keys []string
+
+		for {
+			key, err := readKey(reader)
+
+			if err != nil {
+				break
+			}
+
+			keys = append(keys, key)
+		}
+
+		if !slices.Equal(keys, tc.want) {
+			t.Errorf("readKey(%q) read %q, want %q", tc.input, keys, tc.want)
+		}
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{text: "foo", width: 5, want: "foo  "},
+		{text: "foobar", width: 3, want: "foo"},
+		{text: "\tã", width: 6, want: "    ã "},
+		{text: "foo", width: -1, want: ""},
+	}
+
+	for _, tc := range tests {
+		if result := fitWidth(tc.text, tc.width); result != tc.want {
+			t.Errorf("fitWidth(%q, %d) = %q, want %q", tc.text, tc.width, result, tc.want)
+		}
+	}
+}
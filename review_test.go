@@ -0,0 +1,136 @@
+package fds
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func newTestReview(t *testing.T) (*Review, Options, *MemoryFileSystem) {
+	fileSystem := newTestFileSystem(map[string]string{
+		"/src/a": "foo foo\nbar",
+		"/src/b": "lorem ipsum",
+		"/src/c": "bar\nfoo",
+	}, t)
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+
+	options := Options{Search: "foo", Replace: "baz", Paths: []string{"/src"}, Config: config}
+	review, err := NewReview(context.Background(), options)
+
+	if err != nil {
+		t.Fatalf("NewReview() returned an unexpected error '%s'", err)
+	}
+
+	return review, options, fileSystem
+}
+
+func TestNewReview(t *testing.T) {
+	review, options, fileSystem := newTestReview(t)
+
+	if len(review.Files) != 2 || review.Files[0].Path != "/src/a" || review.Files[1].Path != "/src/c" {
+		t.Fatalf("NewReview() found files %+v, want /src/a and /src/c", review.Files)
+	}
+
+	if matches, selected := review.Count(); matches != 3 || selected != 3 {
+		t.Errorf("Review.Count() = %d, %d, want 3, 3", matches, selected)
+	}
+
+	if options.Config.Confirm {
+		t.Errorf("NewReview() set Config.Confirm of the options supplied")
+	}
+
+	if content, _ := fileSystem.ReadFile("/src/a"); string(content) != "foo foo\nbar" {
+		t.Errorf("NewReview() replaced in /src/a, got %q", content)
+	}
+
+	if before, after := review.Replaced(review.Files[0].Matches[1]); before != "foo foo" || after != "foo baz" {
+		t.Errorf("Review.Replaced() = %q, %q, want %q, %q", before, after, "foo foo", "foo baz")
+	}
+}
+
+func TestNewReview_LineOperations(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"/src/a": "foo\nbar"}, t)
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Operations = LineOperations{Delete: true}
+
+	review, err := NewReview(context.Background(), Options{Search: "foo", Paths: []string{"/src"}, Config: config})
+
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("NewReview() returned error %v, want %v", err, ErrInvalidOption)
+	}
+
+	if matches, _ := review.Count(); matches != 0 {
+		t.Errorf("NewReview() found %d matches, want none", matches)
+	}
+}
+
+func TestReview_Apply(t *testing.T) {
+	review, options, fileSystem := newTestReview(t)
+
+	review.Files[0].Matches[0].Selected = false
+	review.Files[1].Select(false)
+
+	report, err := review.Apply(context.Background(), options)
+
+	if err != nil {
+		t.Fatalf("Review.Apply() returned an unexpected error '%s'", err)
+	}
+
+	if report.Replacements != 1 || len(report.Files) != 1 {
+		t.Errorf("Review.Apply() = %+v, want 1 replacement in 1 file", report)
+	}
+
+	want := map[string]string{"/src/a": "foo baz\nbar", "/src/b": "lorem ipsum", "/src/c": "bar\nfoo"}
+
+	for path, content := range want {
+		if result, _ := fileSystem.ReadFile(path); string(result) != content {
+			t.Errorf("Review.Apply() left %s with %q, want %q", path, result, content)
+		}
+	}
+}
+
+func TestReview_ApplyModifiedSinceReviewed(t *testing.T) {
+	review, options, fileSystem := newTestReview(t)
+
+	fileSystem.WriteFile("/src/a", []byte("foo edited\nbar"), 0o644)
+
+	report, err := review.Apply(context.Background(), options)
+
+	if !errors.Is(err, ErrPartialFailure) {
+		t.Errorf("Review.Apply() returned error %v, want a partial failure", err)
+	}
+
+	if len(report.Files) != 2 || !errors.Is(report.Files[0].Err, ErrAbortedOperation) || !report.Files[1].Replaced {
+		t.Errorf("Review.Apply() = %+v, want /src/a not overwritten and /src/c replaced in", report)
+	}
+
+	want := map[string]string{"/src/a": "foo edited\nbar", "/src/c": "bar\nbaz"}
+
+	for path, content := range want {
+		if result, _ := fileSystem.ReadFile(path); string(result) != content {
+			t.Errorf("Review.Apply() left %s with %q, want %q", path, result, content)
+		}
+	}
+}
+
+func TestReview_ApplyNothingSelected(t *testing.T) {
+	review, options, fileSystem := newTestReview(t)
+
+	for _, file := range review.Files {
+		file.Select(false)
+	}
+
+	report, err := review.Apply(context.Background(), options)
+
+	if err != nil || report.Replaced() {
+		t.Errorf("Review.Apply() = %+v, %v, want nothing replaced", report, err)
+	}
+
+	if result, _ := fileSystem.ReadFile("/src/a"); string(result) != "foo foo\nbar" {
+		t.Errorf("Review.Apply() replaced in /src/a, got %q", result)
+	}
+}
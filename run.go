@@ -0,0 +1,271 @@
+package fds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Options are the inputs of Run
+type Options struct {
+	Search  string
+	Replace string
+
+	// Paths are the files and directories replaced in. When there are none, Input is replaced in and written into Output
+	Paths  []string
+	Input  io.Reader
+	Output io.Writer
+
+	// IgnoreGlobs are not replaced in when walking the directories in Paths
+	IgnoreGlobs IgnoreGlobs
+
+	// Config is usually built with NewConfig, which sets its defaults
+	Config Config
+
+	// Confirm answers the prompts, including the ones about the matches of Input. It is required when
+	// Config.Confirm is set. When not set, files modified while being replaced in are not overwritten
+	Confirm ConfirmFunc
+
+	// Progress is told about each file as it is queued, started and done, one call at a time
+	Progress ProgressFunc
+}
+
+// InputName is the name Input goes by in prompts, in place of the one of a file
+const InputName = "(standard input)"
+
+// ProgressStage tells how far along a file is
+type ProgressStage int
+
+const (
+	FileQueued ProgressStage = iota
+	FileStarted
+	FileDone
+)
+
+// Progress is told to a ProgressFunc. The report in File is only complete on FileDone
+type Progress struct {
+	Stage ProgressStage
+	File  FileReport
+}
+
+type ProgressFunc func(progress Progress)
+
+// FileReport is the outcome of replacing in a single file
+type FileReport struct {
+	Path string
+
+	// Replaced tells whether the file was written
+	Replaced bool
+
+	// Replacements is the number of matches replaced, or of lines operated on, in the file
+	Replacements int
+
+	Err error
+}
+
+// Report is the outcome of Run
+type Report struct {
+	// Files holds one report for each file replaced in, in the order they were found
+	Files []FileReport
+
+	// Replacements is the number of replacements performed in all files, or in Input
+	Replacements int
+
+	// inputChanged tells whether anything was replaced in Input
+	inputChanged bool
+}
+
+func newReport(files []FileReport) Report {
+	report := Report{Files: files}
+
+	for _, file := range files {
+		report.Replacements += file.Replacements
+	}
+
+	return report
+}
+
+// Replaced tells whether anything was replaced, either in any of the files or in Input
+func (r Report) Replaced() bool {
+	for _, file := range r.Files {
+		if file.Replaced {
+			return true
+		}
+	}
+
+	return r.inputChanged
+}
+
+// filesError aggregates the errors of the files that failed, out of `total` files
+func (r Report) filesError(total int) error {
+	var failures []error
+
+	for _, file := range r.Files {
+		if file.Err != nil {
+			failures = append(failures, file.Err)
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return NewFilesError(failures, total)
+}
+
+/**
+ * Run replaces `Search` for `Replace` in the files and directories in `Paths`, or in `Input` when there are none,
+ * as the command line does. Files are reported in the order they were found, directories being walked in lexical
+ * order. When a single file is supplied, its own error is returned. Otherwise, the errors of the files are
+ * aggregated into a FilesError, and each one of them is also found in the report of its file.
+ * Once `ctx` is cancelled, no other file is started and the ones in progress are left untouched. An interrupted
+ * error is then returned along with the report of the files done so far, as it is when a prompt is interrupted
+ */
+func Run(ctx context.Context, options Options) (Report, error) {
+	if len(options.Paths) == 0 {
+		return runOnInput(ctx, options)
+	}
+
+	config := options.Config
+
+	if config.Confirm && options.Confirm == nil {
+		return Report{}, NewConfirmWithoutCallbackError()
+	}
+
+	var roots []PathArg
+
+	for _, path := range options.Paths {
+		args, err := readPathArgs(config.fileSystem(), Args{Search: options.Search, Replace: options.Replace, Subject: path})
+
+		if err != nil {
+			return Report{}, err
+		}
+
+		if err = Validate(args, config); err != nil {
+			return Report{}, err
+		}
+
+		roots = append(roots, args.Path)
+	}
+
+	// directories are walked, and read ahead in parallel, while the files found in them are already being replaced in
+	source := func(ctx context.Context, found func(path string) error) error {
+		for _, root := range roots {
+			var err error
+
+			if root.IsFile() {
+				err = found(root.Value)
+			} else {
+				err = walkFilesParallel(ctx, root.Value, options.IgnoreGlobs, config, found)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	confirm := options.Confirm
+
+	if confirm == nil {
+		confirm = func(Prompt) (Answer, error) { return Answer{Choice: ConfirmNo}, nil }
+	}
+
+	run := fileRun{
+		args:     Args{Search: options.Search, Replace: options.Replace},
+		config:   config,
+		stdout:   io.Discard,
+		confirm:  confirm,
+		progress: options.Progress,
+	}
+	confirmAnswer := ConfirmAnswer(ConfirmNo)
+	done, found, err := run.replaceInFiles(ctx, source, &confirmAnswer)
+	report := newReport(done)
+
+	if err := ctx.Err(); err != nil {
+		return report, NewInterruptedError(err)
+	}
+
+	// prompts interrupted, by Ctrl-C for instance, stop the run as cancelling `ctx` does
+	for _, file := range report.Files {
+		if errors.Is(file.Err, ErrInterrupted) {
+			return report, file.Err
+		}
+	}
+
+	if err != nil {
+		return report, err
+	}
+
+	// a single file fails with its own error, as there is nothing else to report on
+	if len(roots) == 1 && roots[0].IsFile() && len(report.Files) == 1 {
+		return report, report.Files[0].Err
+	}
+
+	return report, report.filesError(found)
+}
+
+func runOnInput(ctx context.Context, options Options) (Report, error) {
+	if options.Input == nil {
+		return Report{}, NewInvalidArgumentsError()
+	}
+
+	subject, err := io.ReadAll(options.Input)
+
+	if err != nil {
+		return Report{}, NewStdinReadError(err)
+	}
+
+	args := Args{Subject: string(subject), Search: options.Search, Replace: options.Replace}
+
+	if err = Validate(args, options.Config); err != nil {
+		return Report{}, err
+	}
+
+	if options.Config.Confirm {
+		return confirmOnInput(ctx, args, options)
+	}
+
+	replacer := NewLineReplacer(args.Search, args.Replace, options.Config).WithLimits(options.Config.Limits)
+	result, replaced := replacer.Replace(args.Subject)
+	report := Report{Replacements: replacer.budget.file, inputChanged: replaced}
+
+	if options.Output != nil {
+		if _, err = io.WriteString(options.Output, result); err != nil {
+			return report, fmt.Errorf("Failed to write output: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+/**
+ * confirmOnInput asks for the matches of Input as they are asked for in a file, named InputName in the prompts, and
+ * writes the whole of it into Output once they are all answered
+ */
+func confirmOnInput(ctx context.Context, args Args, options Options) (Report, error) {
+	if options.Confirm == nil {
+		return Report{}, NewConfirmWithoutCallbackError()
+	}
+
+	replacer := NewFileReplacer(InputName, args.Search, args.Replace, options.Config).WithConfirm(options.Confirm)
+	confirmAnswer := ConfirmAnswer(ConfirmNo)
+	result, changed, err := replacer.reviewContent(ctx, []byte(args.Subject), nil, io.Discard, &confirmAnswer)
+
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Replacements: replacer.replacements(), inputChanged: changed}
+
+	if options.Output != nil {
+		if _, err = options.Output.Write(result); err != nil {
+			return report, fmt.Errorf("Failed to write output: %w", err)
+		}
+	}
+
+	return report, nil
+}
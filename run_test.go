@@ -0,0 +1,249 @@
+package fds
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRun_Input(t *testing.T) {
+	var output bytes.Buffer
+
+	options := Options{Search: "foo", Replace: "bar", Input: strings.NewReader("foo foo\nfoo"), Output: &output, Config: NewConfig()}
+
+	report, err := Run(context.Background(), options)
+
+	if err != nil {
+		t.Fatalf("Run() returned an unexpected error '%s'", err)
+	}
+
+	if want := "bar bar\nbar"; output.String() != want {
+		t.Errorf("Run() wrote %q, want %q", output.String(), want)
+	}
+
+	if !report.Replaced() || report.Replacements != 3 {
+		t.Errorf("Run() = %+v, want 3 replacements", report)
+	}
+}
+
+func TestRun_Files(t *testing.T) {
+	config := NewConfig()
+	config.FileSystem = newTestFileSystem(map[string]string{
+		"/src/input1": "foo foo",
+		"/src/input2": "lorem ipsum",
+		"/src/input3": "foo",
+	}, t)
+
+	var stages []ProgressStage
+
+	options := Options{
+		Search:   "foo",
+		Replace:  "bar",
+		Paths:    []string{"/src"},
+		Config:   config,
+		Progress: func(progress Progress) { stages = append(stages, progress.Stage) },
+	}
+
+	report, err := Run(context.Background(), options)
+
+	if err != nil {
+		t.Fatalf("Run() returned an unexpected error '%s'", err)
+	}
+
+	if len(report.Files) != 3 || report.Replacements != 3 {
+		t.Errorf("Run() = %+v, want 3 files and 3 replacements", report)
+	}
+
+	for _, file := range report.Files {
+		want := path.Base(file.Path) != "input2"
+
+		if file.Replaced != want {
+			t.Errorf("Run() reported %+v, want Replaced %v", file, want)
+		}
+	}
+
+	for _, stage := range []ProgressStage{FileQueued, FileStarted, FileDone} {
+		if count := len(slices.DeleteFunc(slices.Clone(stages), func(s ProgressStage) bool { return s != stage })); count != 3 {
+			t.Errorf("Run() reported stage %d %d times, want 3", stage, count)
+		}
+	}
+}
+
+func TestRun_Confirm(t *testing.T) {
+	inputPath := "input"
+	fileSystem := newTestFileSystem(map[string]string{inputPath: "foo foo\nfoo"}, t)
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Confirm = true
+
+	var prompts []Prompt
+	answers := []rune{ConfirmNo, ConfirmYes, ConfirmQuit}
+
+	options := Options{Search: "foo", Replace: "bar", Paths: []string{inputPath}, Config: config}
+	options.Confirm = func(prompt Prompt) (Answer, error) {
+		prompts = append(prompts, prompt)
+
+		return Answer{Choice: answers[len(prompts)-1]}, nil
+	}
+
+	report, err := Run(context.Background(), options)
+
+	if err != nil {
+		t.Fatalf("Run() returned an unexpected error '%s'", err)
+	}
+
+	result, _ := fileSystem.ReadFile(inputPath)
+
+	if want := "foo bar\nfoo"; string(result) != want {
+		t.Errorf("Run() = %q, want %q", result, want)
+	}
+
+	if len(prompts) != 3 || prompts[2].Match == nil || prompts[2].LineNumber != 2 {
+		t.Errorf("Run() asked %+v, want a prompt for each match", prompts)
+	}
+
+	if report.Replacements != 1 {
+		t.Errorf("Run() reported %d replacements, want 1", report.Replacements)
+	}
+}
+
+func TestRun_ConfirmInput(t *testing.T) {
+	config := NewConfig()
+	config.Confirm = true
+
+	var output bytes.Buffer
+	var prompts []Prompt
+	answers := []rune{ConfirmYes, ConfirmNo, ConfirmYes}
+
+	options := Options{Search: "foo", Replace: "bar", Input: strings.NewReader("foo foo\r\nfoo\n"), Output: &output, Config: config}
+	options.Confirm = func(prompt Prompt) (Answer, error) {
+		prompts = append(prompts, prompt)
+
+		return Answer{Choice: answers[len(prompts)-1]}, nil
+	}
+
+	report, err := Run(context.Background(), options)
+
+	if err != nil {
+		t.Fatalf("Run() returned an unexpected error '%s'", err)
+	}
+
+	if want := "bar foo\r\nbar\n"; output.String() != want {
+		t.Errorf("Run() wrote %q, want %q", output.String(), want)
+	}
+
+	if len(prompts) != 3 || prompts[0].File != InputName || prompts[2].LineNumber != 2 {
+		t.Errorf("Run() asked %+v, want a prompt for each match of the input", prompts)
+	}
+
+	if !report.Replaced() || report.Replacements != 2 {
+		t.Errorf("Run() = %+v, want 2 replacements", report)
+	}
+}
+
+func TestRun_ConfirmNotices(t *testing.T) {
+	inputPath := "input"
+	fileSystem := newTestFileSystem(map[string]string{inputPath: "foo\nfoo\n"}, t)
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Confirm = true
+
+	// undoing with nothing answered, jumping to an invalid pattern, then to a line without a match, before quitting
+	var prompts bytes.Buffer
+	answers := strings.NewReader("u\n/\n[\n/\nbar\nq\n")
+
+	options := Options{Search: "foo", Replace: "bar", Paths: []string{inputPath}, Config: config, Confirm: NewTerminalConfirm(answers, &prompts)}
+
+	if _, err := Run(context.Background(), options); err != nil {
+		t.Fatalf("Run() returned an unexpected error '%s'", err)
+	}
+
+	for _, want := range []string{
+		"Nothing to undo in this file\nFile\tinput\n",
+		"error parsing regexp: missing closing ]: `[`\nFile\tinput\n",
+		"No match after this one on a line matching \"bar\"\nFile\tinput\n",
+	} {
+		if !strings.Contains(prompts.String(), want) {
+			t.Errorf("Run() prompted %q, want it to contain %q", prompts.String(), want)
+		}
+	}
+}
+
+func TestRun_ConfirmInputWithoutCallback(t *testing.T) {
+	config := NewConfig()
+	config.Confirm = true
+
+	_, err := Run(context.Background(), Options{Search: "foo", Replace: "bar", Input: strings.NewReader("foo"), Config: config})
+
+	if !errors.Is(err, ErrConfirmWithoutCallback) {
+		t.Errorf("Run() returned error %v, want ErrConfirmWithoutCallback", err)
+	}
+}
+
+func TestRun_ConfirmWithoutCallback(t *testing.T) {
+	config := NewConfig()
+	config.FileSystem = newTestFileSystem(map[string]string{"/src/input": "foo"}, t)
+	config.Confirm = true
+
+	_, err := Run(context.Background(), Options{Search: "foo", Replace: "bar", Paths: []string{"/src"}, Config: config})
+
+	if !errors.Is(err, ErrConfirmWithoutCallback) {
+		t.Errorf("Run() returned error %v, want ErrConfirmWithoutCallback", err)
+	}
+}
+
+func TestRun_SingleFileError(t *testing.T) {
+	config := NewConfig()
+	config.FileSystem = NewMemoryFileSystem()
+
+	_, err := Run(context.Background(), Options{Search: "foo", Replace: "bar", Paths: []string{"missing"}, Config: config})
+
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Run() returned error %v, want ErrFileNotFound", err)
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	inputPath := "input"
+	fileSystem := newTestFileSystem(map[string]string{inputPath: "foo"}, t)
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := Run(ctx, Options{Search: "foo", Replace: "bar", Paths: []string{inputPath}, Config: config})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() returned error %v, want context.Canceled", err)
+	}
+
+	if result, _ := fileSystem.ReadFile(inputPath); report.Replaced() || string(result) != "foo" {
+		t.Errorf("Run() replaced in %q after being cancelled", result)
+	}
+}
+
+func TestRun_InvalidConfig(t *testing.T) {
+	fileSystem := newTestFileSystem(map[string]string{"/src/input": "foo"}, t)
+
+	config := NewConfig()
+	config.FileSystem = fileSystem
+	config.Limits.PerFile = -1
+
+	_, err := Run(context.Background(), Options{Search: "foo", Replace: "bar", Paths: []string{"/src"}, Config: config})
+
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Run() returned error %v, want ErrInvalidOption", err)
+	}
+
+	if result, _ := fileSystem.ReadFile("/src/input"); string(result) != "foo" {
+		t.Errorf("Run() replaced in %q with an invalid configuration", result)
+	}
+}
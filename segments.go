@@ -0,0 +1,44 @@
+package fds
+
+import "strings"
+
+/**
+ * replacedSegment is a match of a line, at its indexes in the original line, along with the text it is replaced with.
+ * Lines are rebuilt from the text between the segments and the text of each segment, so that matches are always
+ * found and expanded on the original line, whatever was decided for the ones before them
+ */
+type replacedSegment struct {
+	start, end int
+	text       string
+}
+
+// joinSegments rebuilds `line` with the text of each one of `segments`, in order and not overlapping, in place of its match
+func joinSegments(line string, segments []replacedSegment) string {
+	var result strings.Builder
+	var last int
+
+	for _, segment := range segments {
+		result.WriteString(line[last:segment.start])
+		result.WriteString(segment.text)
+		last = segment.end
+	}
+
+	result.WriteString(line[last:])
+
+	return result.String()
+}
+
+// shiftIndexes moves the indexes of a match by `offset`, leaving the ones of the groups that did not match at -1
+func shiftIndexes(indexes []int, offset int) []int {
+	shifted := make([]int, len(indexes))
+
+	for i, index := range indexes {
+		shifted[i] = index
+
+		if index >= 0 {
+			shifted[i] += offset
+		}
+	}
+
+	return shifted
+}
@@ -0,0 +1,224 @@
+package fds
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+var (
+	propertyPatterns     = []string{"a", "ab", "a+", "b*", "(a)(b)?", `\ba`, `\Bb`, "^a", "a$", "(?i)A", "[ab]{2}", "(a|ab)(x)?"}
+	propertyReplacements = []string{"X", "XYZW", "$1", "${1}-$2", "$0$0", "b"}
+	// edits may be empty, unlike the replacement supplied
+	propertyEdits = []string{"", "Y", "edited"}
+)
+
+// replacementCase is a random content, pattern and replacement, along with the answers given to its matches in order
+type replacementCase struct {
+	Lines   []string
+	Pattern string
+	Replace string
+	Answers []Answer
+}
+
+func (replacementCase) Generate(random *rand.Rand, size int) reflect.Value {
+	c := replacementCase{
+		Pattern: propertyPatterns[random.Intn(len(propertyPatterns))],
+		Replace: propertyReplacements[random.Intn(len(propertyReplacements))],
+	}
+
+	for range 1 + random.Intn(4) {
+		line := make([]byte, random.Intn(12))
+
+		for i := range line {
+			line[i] = "ab x"[random.Intn(4)]
+		}
+
+		c.Lines = append(c.Lines, string(line))
+	}
+
+	// the last line is not empty, as a content ending in a line break holds no line after it
+	c.Lines[len(c.Lines)-1] += "x"
+
+	for range 40 {
+		switch random.Intn(3) {
+		case 0:
+			c.Answers = append(c.Answers, Answer{Choice: ConfirmYes})
+		case 1:
+			c.Answers = append(c.Answers, Answer{Choice: ConfirmNo})
+		default:
+			c.Answers = append(c.Answers, Answer{Choice: ConfirmEdit, Replacement: propertyEdits[random.Intn(len(propertyEdits))]})
+		}
+	}
+
+	return reflect.ValueOf(c)
+}
+
+/**
+ * replaceFromRight is the oracle the segments are checked against: it replaces the matches answered to from the last
+ * one to the first one, so that replacing a match does not move the ones left to replace
+ */
+func replaceFromRight(re *regexp.Regexp, replace, line string, submatches [][]int, answers []Answer) string {
+	result := line
+
+	for i := len(submatches) - 1; i >= 0; i-- {
+		submatch := submatches[i]
+
+		switch answers[i].Choice {
+		case ConfirmYes:
+			result = result[:submatch[0]] + string(re.ExpandString(nil, replace, line, submatch)) + result[submatch[1]:]
+		case ConfirmEdit:
+			result = result[:submatch[0]] + answers[i].Replacement + result[submatch[1]:]
+		}
+	}
+
+	return result
+}
+
+var propertyConfig = &quick.Config{MaxCount: 500, Rand: rand.New(rand.NewSource(47))}
+
+func TestReplaceInteractive_AppliesAnswersToTheMatchesShown(t *testing.T) {
+	property := func(c replacementCase) bool {
+		re := regexp.MustCompile(c.Pattern)
+		content := strings.Join(c.Lines, "\n")
+		fileSystem := newTestFileSystem(map[string]string{"input": content}, t)
+
+		config := NewConfig()
+		config.FileSystem = fileSystem
+		config.Confirm = true
+
+		var prompts []Prompt
+
+		options := Options{Search: c.Pattern, Replace: c.Replace, Paths: []string{"input"}, Config: config}
+		options.Confirm = func(prompt Prompt) (Answer, error) {
+			prompts = append(prompts, prompt)
+
+			return c.Answers[(len(prompts)-1)%len(c.Answers)], nil
+		}
+
+		if _, err := Run(context.Background(), options); err != nil {
+			t.Logf("Run() returned an unexpected error '%s'", err)
+
+			return false
+		}
+
+		var want []string
+		var asked int
+
+		for i, line := range c.Lines {
+			submatches := re.FindAllStringSubmatchIndex(line, -1)
+			answers := make([]Answer, len(submatches))
+
+			for j, submatch := range submatches {
+				if asked >= len(prompts) {
+					t.Logf("Run() asked %d prompts, fewer than the matches", len(prompts))
+
+					return false
+				}
+
+				prompt := prompts[asked]
+
+				if prompt.LineNumber != i+1 || prompt.Match.IndexStart != submatch[0] || prompt.Match.Search != line[submatch[0]:submatch[1]] {
+					t.Logf("Run() asked about %+v, want match %v of line %d %q", prompt.Match, submatch, i+1, line)
+
+					return false
+				}
+
+				answers[j] = c.Answers[asked%len(c.Answers)]
+				asked++
+			}
+
+			want = append(want, replaceFromRight(re, c.Replace, line, submatches, answers))
+		}
+
+		result, _ := fileSystem.ReadFile("input")
+
+		if string(result) != strings.Join(want, "\n") {
+			t.Logf("Run() = %q, want %q", result, strings.Join(want, "\n"))
+
+			return false
+		}
+
+		return asked == len(prompts)
+	}
+
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReview_ReplacedOnlyTheMatchShown(t *testing.T) {
+	property := func(c replacementCase) bool {
+		re := regexp.MustCompile(c.Pattern)
+		content := strings.Join(c.Lines, "\n")
+
+		config := NewConfig()
+		config.FileSystem = newTestFileSystem(map[string]string{"input": content}, t)
+
+		review, err := NewReview(context.Background(), Options{Search: c.Pattern, Replace: c.Replace, Paths: []string{"input"}, Config: config})
+
+		if err != nil {
+			t.Logf("NewReview() returned an unexpected error '%s'", err)
+
+			return false
+		}
+
+		for _, file := range review.Files {
+			for _, match := range file.Matches {
+				line := c.Lines[match.LineNumber-1]
+				submatches := re.FindAllStringSubmatchIndex(line, -1)
+				index := slices.IndexFunc(submatches, func(submatch []int) bool { return submatch[0] == match.Match.IndexStart })
+				answers := make([]Answer, len(submatches))
+
+				for i := range answers {
+					answers[i] = Answer{Choice: ConfirmNo}
+				}
+
+				answers[index] = Answer{Choice: ConfirmYes}
+
+				// lines are shorter than the part of them shown around a match, so all of it is shown
+				if before, after := review.Replaced(match); before != line || after != replaceFromRight(re, c.Replace, line, submatches, answers) {
+					t.Logf("Review.Replaced(%+v) = %q, %q on line %q", match.Match, before, after, line)
+
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJoinSegments(t *testing.T) {
+	tests := []struct {
+		line     string
+		segments []replacedSegment
+		want     string
+	}{
+		{line: "foo bar foo", segments: nil, want: "foo bar foo"},
+		{line: "foo bar foo", segments: []replacedSegment{{0, 3, "x"}, {8, 11, "longer"}}, want: "x bar longer"},
+		{line: "foo", segments: []replacedSegment{{0, 0, ">"}, {3, 3, "<"}}, want: ">foo<"},
+		{line: "foo", segments: []replacedSegment{{0, 3, ""}}, want: ""},
+	}
+
+	for _, tc := range tests {
+		if result := joinSegments(tc.line, tc.segments); result != tc.want {
+			t.Errorf("joinSegments(%q, %v) = %q, want %q", tc.line, tc.segments, result, tc.want)
+		}
+	}
+}
+
+func TestShiftIndexes(t *testing.T) {
+	if result := shiftIndexes([]int{4, 8, -1, -1, 5, 6}, -3); !slices.Equal(result, []int{1, 5, -1, -1, 2, 3}) {
+		t.Errorf("shiftIndexes() = %v, want groups that did not match left at -1", result)
+	}
+}
@@ -0,0 +1,174 @@
+package fds
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive range of line numbers, as in `--lines 10:20`. A zero bound means open
+type LineRange struct {
+	From int
+	To   int
+}
+
+func (l *LineRange) String() string {
+	if l.From == 0 && l.To == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s:%s", formatLineBound(l.From), formatLineBound(l.To))
+}
+
+func (l *LineRange) Type() string {
+	return "range"
+}
+
+/**
+ * Set parses ranges in the forms "10:20", "10:" (from line 10 on), ":20" (up to line 20) and "10" (only line 10)
+ */
+func (l *LineRange) Set(value string) error {
+	from, to, isRange := strings.Cut(value, ":")
+
+	if !isRange {
+		to = from
+	}
+
+	var err error
+	var parsed LineRange
+
+	if parsed.From, err = parseLineBound(from); err != nil {
+		return err
+	}
+
+	if parsed.To, err = parseLineBound(to); err != nil {
+		return err
+	}
+
+	if parsed.To > 0 && parsed.From > parsed.To {
+		return fmt.Errorf("line range %q ends before it starts", value)
+	}
+
+	*l = parsed
+
+	return nil
+}
+
+func (l LineRange) Contains(lineNumber int) bool {
+	if l.From > 0 && lineNumber < l.From {
+		return false
+	}
+
+	if l.To > 0 && lineNumber > l.To {
+		return false
+	}
+
+	return true
+}
+
+func parseLineBound(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	bound, err := strconv.Atoi(value)
+
+	if err != nil || bound < 1 {
+		return 0, fmt.Errorf("%q is not a valid line number", value)
+	}
+
+	return bound, nil
+}
+
+func formatLineBound(bound int) string {
+	if bound == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(bound)
+}
+
+// Pattern is a Regular Expression supplied as a flag, compiled as soon as it's set
+type Pattern struct {
+	*regexp.Regexp
+}
+
+func (p *Pattern) String() string {
+	if p.Regexp == nil {
+		return ""
+	}
+
+	return p.Regexp.String()
+}
+
+func (p *Pattern) Type() string {
+	return "regexp"
+}
+
+func (p *Pattern) Set(value string) error {
+	compiled, err := regexp.Compile(value)
+
+	if err != nil {
+		return err
+	}
+
+	p.Regexp = compiled
+
+	return nil
+}
+
+func (p Pattern) IsSet() bool {
+	return p.Regexp != nil
+}
+
+/**
+ * Selection restricts replacements to some lines of each file, similar to sed addresses:
+ * a range of line numbers, regions that start after a line matching `After` and end before a line matching `Before`,
+ * and lines matching `Where`. All criteria supplied must be satisfied for a line to be selected
+ */
+type Selection struct {
+	Lines  LineRange
+	After  Pattern
+	Before Pattern
+	Where  Pattern
+}
+
+func (s Selection) IsSet() bool {
+	return s.Lines != LineRange{} || s.After.IsSet() || s.Before.IsSet() || s.Where.IsSet()
+}
+
+// lineSelector walks the lines of a single file, keeping track of whether it's inside an After/Before region
+type lineSelector struct {
+	Selection
+
+	inRegion bool
+}
+
+func (s Selection) newLineSelector() *lineSelector {
+	return &lineSelector{Selection: s, inRegion: !s.After.IsSet()}
+}
+
+/**
+ * Selects must be called for every line of the file, in order, as the lines delimiting regions change its state.
+ * Delimiting lines are never selected themselves
+ */
+func (s *lineSelector) Selects(lineNumber int, line string) bool {
+	if !s.inRegion {
+		s.inRegion = s.After.IsSet() && s.After.MatchString(line)
+
+		return false
+	}
+
+	if s.Before.IsSet() && s.Before.MatchString(line) {
+		s.inRegion = false
+
+		return false
+	}
+
+	if s.Where.IsSet() && !s.Where.MatchString(line) {
+		return false
+	}
+
+	return s.Lines.Contains(lineNumber)
+}
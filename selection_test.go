@@ -0,0 +1,121 @@
+package fds
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineRange_Set(t *testing.T) {
+	var tests = []struct {
+		value       string
+		want        LineRange
+		expectError bool
+	}{
+		{value: "10:20", want: LineRange{From: 10, To: 20}},
+		{value: "10:", want: LineRange{From: 10}},
+		{value: ":20", want: LineRange{To: 20}},
+		{value: "7", want: LineRange{From: 7, To: 7}},
+		{value: "20:10", expectError: true},
+		{value: "0:10", expectError: true},
+		{value: "a:b", expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			var result LineRange
+			err := result.Set(tc.value)
+
+			if tc.expectError && err == nil {
+				t.Errorf("LineRange.Set(%q) expects error, none returned", tc.value)
+			}
+
+			if !tc.expectError && result != tc.want {
+				t.Errorf("LineRange.Set(%q) = %+v, want %+v", tc.value, result, tc.want)
+			}
+		})
+	}
+}
+
+func TestLineSelector_Selects(t *testing.T) {
+	lines := []string{"BEGIN", "import foo", "foo", "END", "foo", "BEGIN", "foo", "import bar"}
+
+	var tests = []struct {
+		name      string
+		selection func() Selection
+		want      string
+	}{
+		{
+			name:      "nothing set selects every line",
+			selection: func() Selection { return Selection{} },
+			want:      "11111111",
+		},
+		{
+			name: "line range",
+			selection: func() Selection {
+				return Selection{Lines: LineRange{From: 2, To: 4}}
+			},
+			want: "01110000",
+		},
+		{
+			name: "where",
+			selection: func() Selection {
+				var selection Selection
+				selection.Where.Set("^import")
+
+				return selection
+			},
+			want: "01000001",
+		},
+		{
+			name: "after and before regions",
+			selection: func() Selection {
+				var selection Selection
+				selection.After.Set("BEGIN")
+				selection.Before.Set("END")
+
+				return selection
+			},
+			want: "01100011",
+		},
+		{
+			name: "before only",
+			selection: func() Selection {
+				var selection Selection
+				selection.Before.Set("END")
+
+				return selection
+			},
+			want: "11100000",
+		},
+		{
+			name: "after combined with where",
+			selection: func() Selection {
+				var selection Selection
+				selection.After.Set("BEGIN")
+				selection.Where.Set("import")
+
+				return selection
+			},
+			want: "01000001",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var result strings.Builder
+			selector := tc.selection().newLineSelector()
+
+			for i, line := range lines {
+				if selector.Selects(i+1, line) {
+					result.WriteByte('1')
+				} else {
+					result.WriteByte('0')
+				}
+			}
+
+			if result.String() != tc.want {
+				t.Errorf("lineSelector.Selects() = %s, want %s", result.String(), tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package fds
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// directoriesAheadPerWalker bounds how many directories each walker reads before their files are handed out
+const directoriesAheadPerWalker = 64
+
+/**
+ * parallelWalker walks a tree with several goroutines reading directories ahead, each one taking directories from
+ * its own queue and stealing from the queues of the others once it runs out. Files are still handed out in the
+ * lexical order of fs.WalkDir, as the directories are read ahead but walked into one at a time
+ */
+type parallelWalker struct {
+	fileSystem FileSystem
+	queues     []*directoryQueue
+
+	// wake tells idle walkers that directories were queued
+	wake chan struct{}
+
+	// ahead holds a token for each directory read ahead whose files were not handed out yet
+	ahead chan struct{}
+}
+
+// directoryListing is a directory read, or to be read, by whoever claims it first
+type directoryListing struct {
+	path    string
+	claimed atomic.Bool
+	done    chan struct{}
+
+	entries []fs.DirEntry
+	err     error
+
+	// subdirectories holds the listings of the directories among entries, in the same order
+	subdirectories []*directoryListing
+
+	// readAhead tells whether a walker read it, rather than the goroutine handing out files
+	readAhead bool
+}
+
+func newDirectoryListing(path string) *directoryListing {
+	return &directoryListing{path: path, done: make(chan struct{})}
+}
+
+// directoryQueue is a double-ended queue: its owner takes from the back, the other walkers steal from the front
+type directoryQueue struct {
+	mutex    sync.Mutex
+	listings []*directoryListing
+}
+
+func (q *directoryQueue) push(listings ...*directoryListing) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.listings = append(q.listings, listings...)
+}
+
+func (q *directoryQueue) pop() *directoryListing {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.listings) == 0 {
+		return nil
+	}
+
+	listing := q.listings[len(q.listings)-1]
+	q.listings = q.listings[:len(q.listings)-1]
+
+	return listing
+}
+
+func (q *directoryQueue) steal() *directoryListing {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.listings) == 0 {
+		return nil
+	}
+
+	listing := q.listings[0]
+	q.listings = q.listings[1:]
+
+	return listing
+}
+
+func newParallelWalker(fileSystem FileSystem, walkers int) *parallelWalker {
+	walker := &parallelWalker{
+		fileSystem: fileSystem,
+		wake:       make(chan struct{}, 1),
+		ahead:      make(chan struct{}, walkers*directoriesAheadPerWalker),
+	}
+
+	for range walkers {
+		walker.queues = append(walker.queues, &directoryQueue{})
+	}
+
+	return walker
+}
+
+/**
+ * walkFilesParallel hands each file under `root` to `found`, in the same order and with the same errors as
+ * walkFiles, while the directories further down the tree are read ahead in parallel
+ */
+func walkFilesParallel(ctx context.Context, root string, ignoreGlobs IgnoreGlobs, config Config, found func(path string) error) error {
+	config.logf("Ignoring glob patterns \"%s\"\n", ignoreGlobs.String())
+
+	walkCtx, stopWalkers := context.WithCancel(ctx)
+	walker := newParallelWalker(config.fileSystem(), config.workers())
+
+	var wg sync.WaitGroup
+
+	for id := range walker.queues {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			walker.work(walkCtx, id)
+		}()
+	}
+
+	defer wg.Wait()
+	defer stopWalkers()
+
+	return walker.handOut(ctx, newDirectoryListing(root), ignoreGlobs, config, found)
+}
+
+// work reads the directories queued until `ctx` is done, up to the number of directories allowed ahead
+func (w *parallelWalker) work(ctx context.Context, id int) {
+	for {
+		listing := w.take(id)
+
+		if listing == nil {
+			select {
+			case <-w.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// more walkers may have been woken for the directories left
+		w.signal()
+
+		select {
+		case w.ahead <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		if !listing.claimed.CompareAndSwap(false, true) {
+			<-w.ahead
+
+			continue
+		}
+
+		listing.readAhead = true
+		w.read(listing, id)
+	}
+}
+
+// take takes the latest directory queued by walker `id`, or the oldest one queued by any other walker
+func (w *parallelWalker) take(id int) *directoryListing {
+	if listing := w.queues[id].pop(); listing != nil {
+		return listing
+	}
+
+	for i := 1; i < len(w.queues); i++ {
+		if listing := w.queues[(id+i)%len(w.queues)].steal(); listing != nil {
+			return listing
+		}
+	}
+
+	return nil
+}
+
+func (w *parallelWalker) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// read reads a claimed directory, queuing its subdirectories to be read by walker `id`
+func (w *parallelWalker) read(listing *directoryListing, id int) {
+	defer close(listing.done)
+
+	listing.entries, listing.err = w.fileSystem.ReadDir(listing.path)
+
+	for _, entry := range listing.entries {
+		if entry.IsDir() {
+			listing.subdirectories = append(listing.subdirectories, newDirectoryListing(filepath.Join(listing.path, entry.Name())))
+		}
+	}
+
+	if len(listing.subdirectories) > 0 {
+		w.queues[id].push(listing.subdirectories...)
+		w.signal()
+	}
+}
+
+// wait waits for `listing` to be read, reading it right away when no walker claimed it yet
+func (w *parallelWalker) wait(listing *directoryListing) {
+	if listing.claimed.CompareAndSwap(false, true) {
+		w.read(listing, 0)
+
+		return
+	}
+
+	<-listing.done
+
+	if listing.readAhead {
+		<-w.ahead
+	}
+}
+
+func (w *parallelWalker) handOut(ctx context.Context, listing *directoryListing, ignoreGlobs IgnoreGlobs, config Config, found func(path string) error) error {
+	w.wait(listing)
+
+	if listing.err != nil {
+		return NewDirectoryReadError(listing.path, listing.err)
+	}
+
+	subdirectory := 0
+
+	for _, entry := range listing.entries {
+		if err := ctx.Err(); err != nil {
+			return NewInterruptedError(err)
+		}
+
+		path := filepath.Join(listing.path, entry.Name())
+
+		if entry.IsDir() {
+			err := w.handOut(ctx, listing.subdirectories[subdirectory], ignoreGlobs, config, found)
+
+			// the files of the subdirectory were handed out, so its listing can be let go of
+			listing.subdirectories[subdirectory] = nil
+			subdirectory++
+
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if ignoreGlobs.MatchAny(path) {
+			config.logf("Pattern matched path \"%s\"\n", path)
+
+			continue
+		}
+
+		if err := found(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
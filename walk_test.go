@@ -0,0 +1,206 @@
+package fds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestDeepTree creates `width` directories on each of `depth` levels under /src, with `files` files in each one
+func newTestDeepTree(width, depth, files int, t testing.TB) *MemoryFileSystem {
+	fileSystem := NewMemoryFileSystem()
+
+	var fill func(dir string, level int)
+
+	fill = func(dir string, level int) {
+		for i := range files {
+			if err := fileSystem.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d", i)), nil, 0644); err != nil {
+				t.Fatalf("Failed to create test file: %s", err)
+			}
+		}
+
+		if level == depth {
+			return
+		}
+
+		for i := range width {
+			fill(filepath.Join(dir, fmt.Sprintf("dir%d", i)), level+1)
+		}
+	}
+
+	fill("/src", 0)
+
+	return fileSystem
+}
+
+func walkParallel(ctx context.Context, root string, ignoreGlobs IgnoreGlobs, config Config) ([]string, error) {
+	var files []string
+
+	err := walkFilesParallel(ctx, root, ignoreGlobs, config, func(path string) error {
+		files = append(files, path)
+
+		return nil
+	})
+
+	return files, err
+}
+
+func TestWalkFilesParallel_SameOrderAsWalkFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileSystem  *MemoryFileSystem
+		ignoreGlobs IgnoreGlobs
+		workers     int
+	}{
+		{name: "Tree, single walker", fileSystem: newTestTreeStructure(t), workers: 1},
+		{name: "Tree, several walkers", fileSystem: newTestTreeStructure(t), workers: 8},
+		{name: "Tree with ignore globs", fileSystem: newTestTreeStructure(t), ignoreGlobs: IgnoreGlobs{"/src/dir2/**"}, workers: 4},
+		{name: "Deep tree", fileSystem: newTestDeepTree(3, 4, 2, t), workers: 8},
+		{name: "Deep tree with ignore globs", fileSystem: newTestDeepTree(3, 3, 2, t), ignoreGlobs: IgnoreGlobs{"/src/dir1/**", "**/file0"}, workers: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := NewConfig()
+			config.FileSystem = test.fileSystem
+			config.Workers = test.workers
+
+			want, err := walkDir(context.Background(), "/src", test.ignoreGlobs, config)
+
+			if err != nil {
+				t.Fatalf("walkDir() returned an unexpected error '%s'", err)
+			}
+
+			result, err := walkParallel(context.Background(), "/src", test.ignoreGlobs, config)
+
+			if err != nil {
+				t.Fatalf("walkFilesParallel() returned an unexpected error '%s'", err)
+			}
+
+			if !reflect.DeepEqual(result, want) {
+				t.Errorf("walkFilesParallel() = %q, want %q", result, want)
+			}
+		})
+	}
+}
+
+func TestWalkFilesParallel_NotFound(t *testing.T) {
+	config := NewConfig()
+	config.FileSystem = newTestTreeStructure(t)
+
+	_, err := walkParallel(context.Background(), "/missing", IgnoreGlobs{}, config)
+
+	if !errors.Is(err, ErrDirectoryRead) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("walkFilesParallel() returned error %v, want a directory read error", err)
+	}
+}
+
+func TestWalkFilesParallel_StopsOnError(t *testing.T) {
+	config := NewConfig()
+	config.FileSystem = newTestDeepTree(4, 3, 4, t)
+	config.Workers = 4
+
+	var found int
+
+	err := walkFilesParallel(context.Background(), "/src", IgnoreGlobs{}, config, func(path string) error {
+		found++
+
+		if found == 10 {
+			return errTestFailure
+		}
+
+		return nil
+	})
+
+	if !errors.Is(err, errTestFailure) || found != 10 {
+		t.Errorf("walkFilesParallel() returned error %v after %d files, want errTestFailure after 10", err, found)
+	}
+}
+
+func TestWalkFilesParallel_Cancelled(t *testing.T) {
+	config := NewConfig()
+	config.FileSystem = newTestDeepTree(2, 2, 2, t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	files, err := walkParallel(ctx, "/src", IgnoreGlobs{}, config)
+
+	if !errors.Is(err, ErrInterrupted) || len(files) > 0 {
+		t.Errorf("walkFilesParallel() = %q, %v, want no files and an interrupted error", files, err)
+	}
+}
+
+// createTestDeepTree creates the tree of newTestDeepTree on disk, under `root`
+func createTestDeepTree(root string, width, depth, files int, b *testing.B) {
+	for _, name := range newTestDeepTree(width, depth, files, b).Names() {
+		path := filepath.Join(root, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			b.Fatalf("Failed to create test directory: %s", err)
+		}
+
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			b.Fatalf("Failed to create test file: %s", err)
+		}
+	}
+}
+
+// slowFileSystem reads directories with a latency, as network file systems, or disks with a cold cache, do
+type slowFileSystem struct {
+	FileSystem
+	latency time.Duration
+}
+
+func (s slowFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	time.Sleep(s.latency)
+
+	return s.FileSystem.ReadDir(name)
+}
+
+/**
+ * benchmarkWalk walks a tree of 259 directories, on disk with a warm cache, where reading directories ahead hardly
+ * pays off, and with slow directory reads, where it does
+ */
+func benchmarkWalk(b *testing.B, walk func(ctx context.Context, root string, ignoreGlobs IgnoreGlobs, config Config, found func(path string) error) error) {
+	// walking on disk is measured against walking in memory, with slow directory reads
+	tempDir := b.TempDir()
+	createTestDeepTree(tempDir, 6, 3, 8, b)
+
+	benchmarks := []struct {
+		name       string
+		root       string
+		fileSystem FileSystem
+	}{
+		{name: "Disk", root: filepath.Join(tempDir, "src"), fileSystem: OSFileSystem{}},
+		{name: "Slow directory reads", root: "/src", fileSystem: slowFileSystem{FileSystem: newTestDeepTree(6, 3, 8, b), latency: 100 * time.Microsecond}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			config := NewConfig()
+			config.Workers = 8
+			config.FileSystem = bm.fileSystem
+
+			for b.Loop() {
+				if err := walk(context.Background(), bm.root, IgnoreGlobs{}, config, func(string) error { return nil }); err != nil {
+					b.Fatalf("Walking returned an unexpected error '%s'", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWalkFiles(b *testing.B) {
+	benchmarkWalk(b, walkFiles)
+}
+
+func BenchmarkWalkFilesParallel(b *testing.B) {
+	benchmarkWalk(b, walkFilesParallel)
+}